@@ -2,22 +2,36 @@ package clock
 
 import "time"
 
-const DAY_IN_SECONDS = 24 * 60 * 60
-
 type Clock interface {
+	Now() time.Time
 	Unix() int64
-	CalculateAge(reference int64) float64
+	CalculateAge(reference int64) time.Duration
+	After(d time.Duration) <-chan time.Time
 }
 
 type RealClock struct{}
 
+func (r RealClock) Now() time.Time {
+	return time.Now()
+}
+
 func (r RealClock) Unix() int64 {
 	return time.Now().Unix()
 }
 
 // CalculateAge Takes a reference date and returns the difference
-// between now and the given date in days
-func (r RealClock) CalculateAge(reference int64) float64 {
-	diff := float64(r.Unix() - reference)
-	return diff / DAY_IN_SECONDS
+// between now and the given date
+func (r RealClock) CalculateAge(reference int64) time.Duration {
+	return age(r.Unix(), reference)
+}
+
+// After waits for the duration to elapse and then sends the current time
+// on the returned channel
+func (r RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// age returns the difference between two unix timestamps
+func age(now int64, reference int64) time.Duration {
+	return time.Duration(now-reference) * time.Second
 }
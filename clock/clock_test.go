@@ -27,7 +27,7 @@ func TestCalculateAgeReturnsPositiveForPastReference(t *testing.T) {
 	past := time.Now().Add(-48 * time.Hour).Unix()
 	age := realClock.CalculateAge(past)
 
-	assert.Positive(t, age, "Expected age to be positive, got %f", age)
+	assert.Positive(t, age, "Expected age to be positive, got %v", age)
 }
 
 func TestCalculateAgeReturnsNegativeForFutureReference(t *testing.T) {
@@ -35,5 +35,38 @@ func TestCalculateAgeReturnsNegativeForFutureReference(t *testing.T) {
 	future := time.Now().Add(48 * time.Hour).Unix()
 	age := realClock.CalculateAge(future)
 
-	assert.Negative(t, age, "Expected age to be negative, got %f", age)
+	assert.Negative(t, age, "Expected age to be negative, got %v", age)
+}
+
+func TestFakeClockAfterFiresOnceAdvanced(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := NewFakeClock(start)
+
+	channel := fakeClock.After(time.Hour)
+	fakeClock.BlockUntil(1)
+
+	fakeClock.Advance(59 * time.Minute)
+	select {
+	case <-channel:
+		t.Fatal("fired before its deadline")
+	default:
+	}
+
+	fakeClock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Hour), <-channel)
+	assert.Equal(t, start.Add(time.Hour), fakeClock.Now())
+	assert.Equal(t, start.Add(time.Hour).Unix(), fakeClock.Unix())
+}
+
+func TestFakeClockAfterFiresAtOnceWhenDue(t *testing.T) {
+	fakeClock := NewFakeClock(time.Unix(0, 0))
+
+	assert.Equal(t, time.Unix(0, 0), <-fakeClock.After(0))
+	assert.Equal(t, time.Unix(0, 0), <-fakeClock.After(-time.Second))
+}
+
+func TestFakeClockCalculateAge(t *testing.T) {
+	fakeClock := NewFakeClock(time.Unix(3*24*60*60, 0))
+
+	assert.Equal(t, 36*time.Hour, fakeClock.CalculateAge(36*60*60))
 }
@@ -0,0 +1,96 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a Clock whose time only moves when advanced, so that code
+// waiting on it can be tested deterministically
+type FakeClock struct {
+	mutex   sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	deadline time.Time
+	channel  chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	clock := &FakeClock{now: now}
+	clock.changed = sync.NewCond(&clock.mutex)
+
+	return clock
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) Unix() int64 {
+	return c.Now().Unix()
+}
+
+// CalculateAge Takes a reference date and returns the difference
+// between the fake time and the given date
+func (c *FakeClock) CalculateAge(reference int64) time.Duration {
+	return age(c.Unix(), reference)
+}
+
+// After returns a channel receiving the fake time once it has been
+// advanced by the duration
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	channel := make(chan time.Time, 1)
+
+	if d <= 0 {
+		channel <- c.now
+		return channel
+	}
+
+	c.waiters = append(c.waiters, waiter{deadline: c.now.Add(d), channel: channel})
+	c.changed.Broadcast()
+
+	return channel
+}
+
+// Advance moves the time forward, firing the channels whose deadline
+// has been reached
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := make([]waiter, 0, len(c.waiters))
+
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+
+		w.channel <- c.now
+	}
+
+	c.waiters = pending
+	c.changed.Broadcast()
+}
+
+// BlockUntil waits until count channels returned by After are waiting
+// for the time to be advanced
+func (c *FakeClock) BlockUntil(count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for len(c.waiters) < count {
+		c.changed.Wait()
+	}
+}
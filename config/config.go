@@ -1,8 +1,10 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"fileman/fs"
+	"os"
+	"path/filepath"
 )
 
 type ConfigHandler struct {
@@ -19,26 +21,250 @@ type IConfigHandler interface {
 	Load() (Config, error)
 }
 
+// Load reads the configuration file, in the format of its extension, and
+// merges into it the fragments it includes and the ones of its conf.d
+// directory. The references in their values are resolved before they are
+// decoded and validated.
 func (h ConfigHandler) Load() (Config, error) {
 	config := Config{}
 
+	if err := h.loadFile(h.config, &config); err != nil {
+		return config, err
+	}
+
+	files, err := h.fragments(config)
+
+	if err != nil {
+		return config, err
+	}
+
+	if len(files) == 0 {
+		return config, config.Validate()
+	}
+
+	config.origins = make([]origin, 0, len(config.WatchedDirectories))
+	for i := range config.WatchedDirectories {
+		config.origins = append(config.origins, origin{file: h.config, index: i})
+	}
+
+	errs := problems{}
+
+	for _, file := range files {
+		part := fragment{}
+
+		if err := h.loadFile(file, &part); err != nil {
+			errs.addFile(file, err)
+			continue
+		}
+
+		for i, directory := range part.WatchedDirectories {
+			config.WatchedDirectories = append(config.WatchedDirectories, directory)
+			config.origins = append(config.origins, origin{file: file, index: i})
+		}
+	}
+
+	if len(errs) > 0 {
+		return config, errors.Join(errs...)
+	}
+
+	return config, config.Validate()
+}
+
+// loadFile reads a configuration file into a Config or a fragment
+func (h ConfigHandler) loadFile(path string, target any) error {
+	decoder, err := decoderFor(path)
+
+	if err != nil {
+		return err
+	}
+
 	fileSystem := fs.FS{}
-	content, readError := fileSystem.ReadFile(h.config)
+	content, readError := fileSystem.ReadFile(path)
 
 	if readError != nil {
-		return config, readError
+		return readError
+	}
+
+	document, err := decoder(content)
+
+	if err != nil {
+		return err
 	}
 
-	err := json.Unmarshal(content, &config)
+	resolver := interpolator{
+		lookupEnv: os.LookupEnv,
+		readFile:  fileSystem.ReadFile,
+		dir:       filepath.Dir(path),
+	}
+	errs := problems{}
+	document = resolver.document(&errs, "", document)
 
-	return config, err
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	return decode(document, target)
 }
 
 type WatchedDirectory struct {
+	Path            string
+	Cron            string
+	Trigger         string
+	Timezone        string
+	Windows         []Window
+	OutsideWindow   string
+	Overlap         string
+	Lock            *Lock
+	RunOnStart      bool
+	Age             Duration
+	Recursive       bool
+	MaxDepth        int
+	RemoveEmptyDirs bool
+	Include         []string
+	Exclude         []string
+	KeepLatest      int
+	MaxTotalSize    Size
+	Watermark       *Watermark
+	AgeSource       string
+	Filename        *FilenameDate
+	Retention       string
+	GFS             *GFS
+	DryRun          *bool
+	Action          string
+	Trash           *Trash
+	Archive         *Archive
+	Actions         []Action
+}
+
+// IsDryRun tells whether the files of the directory are only reported,
+// none of them being removed
+func (d WatchedDirectory) IsDryRun() bool {
+	return d.DryRun != nil && *d.DryRun
+}
+
+// Archive is the directory the archive action stores, in a single file
+// per pass, the files removed from a watched directory. Format is tar.gz
+// (default) or zip.
+type Archive struct {
+	Path   string
+	Format string
+}
+
+// Action is a stage of a watched directory actions pipeline, applied to the
+// files older than After. Type is compress, with the gzip (default) or
+// zstd Format, delete, trash or archive.
+type Action struct {
+	Type   string
+	After  Duration
+	Format string
+}
+
+// Lock makes the replicas of fileman sharing the watched directories take
+// turns, each run over a directory holding its lease, a file in Dir that
+// expires TTL seconds (60 by default) after its holder stopped renewing it.
+type Lock struct {
+	Dir string
+	TTL int
+}
+
+// Trash is the quarantine directory files are moved to by the trash
+// action. Trashed files are permanently deleted once older than Age, or
+// kept forever when Age is 0.
+type Trash struct {
 	Path string
-	Age  float64
+	Age  Duration
+}
+
+// FilenameDate reads the date of a file from its name: the part matching
+// Pattern, restricted to its capture group named date if any, is parsed
+// with the Go time Layout. Files whose name doesn't hold a date are
+// reported as errors, or skipped with OnError set to "skip".
+type FilenameDate struct {
+	Pattern string
+	Layout  string
+	OnError string
+}
+
+// GFS is a grandfather-father-son rotation, keeping the newest file of the
+// last Daily days, Weekly weeks, Monthly months and Yearly years.
+type GFS struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+}
+
+// Keeps tells whether the rotation keeps any backup at all, a rotation
+// without counts rotating every file out
+func (g *GFS) Keeps() bool {
+	return g != nil && (g.Daily > 0 || g.Weekly > 0 || g.Monthly > 0 || g.Yearly > 0)
+}
+
+// Watermark triggers a cleanup when the free space of the filesystem
+// holding a watched directory drops below Low, deleting the oldest files
+// until it reaches High. Free space is checked every Interval seconds.
+type Watermark struct {
+	Low      Threshold
+	High     Threshold
+	Interval int
 }
 type Config struct {
 	Cron               string
+	Timezone           string
+	Windows            []Window
+	OutsideWindow      string
+	Overlap            string
+	Lock               *Lock
+	RunOnStart         bool
+	State              string
+	GracePeriod        int
+	DryRun             bool
+	Include            []string
 	WatchedDirectories []WatchedDirectory
+
+	// origins tells, once fragments are merged, where each of the watched
+	// directories is defined
+	origins []origin
+}
+
+// Directories returns the watched directories with the global settings
+// applied to each of them
+func (c Config) Directories() []WatchedDirectory {
+	directories := make([]WatchedDirectory, 0, len(c.WatchedDirectories))
+
+	for _, directory := range c.WatchedDirectories {
+		if directory.Cron == "" {
+			directory.Cron = c.Cron
+		}
+
+		if directory.Timezone == "" {
+			directory.Timezone = c.Timezone
+		}
+
+		if directory.Windows == nil {
+			directory.Windows = c.Windows
+		}
+
+		if directory.OutsideWindow == "" {
+			directory.OutsideWindow = c.OutsideWindow
+		}
+
+		if directory.Overlap == "" {
+			directory.Overlap = c.Overlap
+		}
+
+		if directory.Lock == nil {
+			directory.Lock = c.Lock
+		}
+
+		directory.RunOnStart = directory.RunOnStart || c.RunOnStart
+		if directory.DryRun == nil {
+			dryRun := c.DryRun
+			directory.DryRun = &dryRun
+		}
+
+		directories = append(directories, directory)
+	}
+
+	return directories
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"testing"
+	"time"
 )
 
 func TestParseValidConfig(t *testing.T) {
@@ -20,11 +21,11 @@ func TestParseValidConfig(t *testing.T) {
 	assert.Equal(t, "* * * * *", config.Cron)
 	assert.Equal(t, []WatchedDirectory{
 		{
-			Path: "foo/bar",
-			Age:  1.5,
+			Path: "/foo/bar",
+			Age:  Days(1.5),
 		}, {
-			Path: "bar/foo",
-			Age:  2.0,
+			Path: "/bar/foo",
+			Age:  Days(2),
 		},
 	}, config.WatchedDirectories)
 }
@@ -43,3 +44,115 @@ func TestParseInvalidConfig(t *testing.T) {
 	assert.Equal(t, []WatchedDirectory(nil), config.WatchedDirectories)
 	assert.Error(t, err)
 }
+
+func TestDirectoriesApplyGlobalDryRun(t *testing.T) {
+	dryRun, wetRun := true, false
+	config := Config{
+		DryRun: true,
+		WatchedDirectories: []WatchedDirectory{
+			{Path: "foo/bar"},
+			{Path: "bar/foo", DryRun: &dryRun},
+			{Path: "foo/baz", DryRun: &wetRun},
+		},
+	}
+
+	directories := config.Directories()
+
+	assert.True(t, directories[0].IsDryRun())
+	assert.True(t, directories[1].IsDryRun())
+	assert.False(t, directories[2].IsDryRun())
+	assert.Nil(t, config.WatchedDirectories[0].DryRun)
+	assert.True(t, Config{WatchedDirectories: []WatchedDirectory{{DryRun: &dryRun}}}.Directories()[0].IsDryRun())
+	assert.False(t, Config{WatchedDirectories: []WatchedDirectory{{}}}.Directories()[0].IsDryRun())
+}
+
+func TestDirectoriesApplyGlobalCron(t *testing.T) {
+	config := Config{
+		Cron: "0 * * * *",
+		WatchedDirectories: []WatchedDirectory{
+			{Path: "foo/bar"},
+			{Path: "bar/foo", Cron: "*/5 * * * *"},
+		},
+	}
+
+	directories := config.Directories()
+
+	assert.Equal(t, "0 * * * *", directories[0].Cron)
+	assert.Equal(t, "*/5 * * * *", directories[1].Cron)
+}
+
+func TestParsePerDirectoryCronConfig(t *testing.T) {
+	configHandler := ConfigHandler{
+		config: "testdata/config_cron.json",
+	}
+
+	config, err := configHandler.Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", config.Cron)
+	assert.Equal(t, "30 2 * * *", config.WatchedDirectories[0].Cron)
+	assert.Equal(t, "CRON_TZ=Europe/Paris */10 * * * *", config.WatchedDirectories[1].Cron)
+}
+
+func TestDirectoriesApplyGlobalSchedule(t *testing.T) {
+	windows := []Window{{Start: time.Hour, End: 5 * time.Hour}}
+	config := Config{
+		Timezone:      "Europe/Berlin",
+		Windows:       windows,
+		OutsideWindow: "defer",
+		Overlap:       "queue",
+		Lock:          &Lock{Dir: "/locks"},
+		WatchedDirectories: []WatchedDirectory{
+			{Path: "foo/bar"},
+			{Path: "bar/foo", Timezone: "UTC", Windows: []Window{}, OutsideWindow: "skip", Overlap: "wait", Lock: &Lock{Dir: "/bar"}},
+		},
+	}
+
+	directories := config.Directories()
+
+	assert.Equal(t, "Europe/Berlin", directories[0].Timezone)
+	assert.Equal(t, windows, directories[0].Windows)
+	assert.Equal(t, "defer", directories[0].OutsideWindow)
+	assert.Equal(t, "queue", directories[0].Overlap)
+	assert.Equal(t, &Lock{Dir: "/locks"}, directories[0].Lock)
+	assert.Equal(t, "UTC", directories[1].Timezone)
+	assert.Equal(t, []Window{}, directories[1].Windows)
+	assert.Equal(t, "skip", directories[1].OutsideWindow)
+	assert.Equal(t, "wait", directories[1].Overlap)
+	assert.Equal(t, &Lock{Dir: "/bar"}, directories[1].Lock)
+}
+
+func TestCrontab(t *testing.T) {
+	assert.Equal(t, "0 3 * * *", WatchedDirectory{Cron: "0 3 * * *"}.Crontab())
+	assert.Equal(t, "CRON_TZ=Europe/Berlin 0 3 * * *", WatchedDirectory{Cron: "0 3 * * *", Timezone: "Europe/Berlin"}.Crontab())
+	assert.Equal(t, "TZ=UTC 0 3 * * *", WatchedDirectory{Cron: "TZ=UTC 0 3 * * *", Timezone: "Europe/Berlin"}.Crontab())
+}
+
+func TestLocation(t *testing.T) {
+	location, err := WatchedDirectory{}.Location()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Local, location)
+
+	location, err = WatchedDirectory{Timezone: "Europe/Berlin"}.Location()
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", location.String())
+
+	_, err = WatchedDirectory{Timezone: "Berlin"}.Location()
+	assert.Error(t, err)
+}
+
+func TestDirectoriesApplyGlobalRunOnStart(t *testing.T) {
+	config := Config{
+		RunOnStart: true,
+		WatchedDirectories: []WatchedDirectory{
+			{Path: "foo/bar"},
+			{Path: "bar/foo", RunOnStart: true},
+		},
+	}
+
+	for _, directory := range config.Directories() {
+		assert.True(t, directory.RunOnStart, directory.Path)
+	}
+
+	assert.False(t, Config{WatchedDirectories: []WatchedDirectory{{}}}.Directories()[0].RunOnStart)
+}
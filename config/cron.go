@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ParseCron parses a 5-field cron expression, optionally prefixed with
+// TZ= or CRON_TZ=, the way the scheduler does. Expressions that never
+// fire, such as "0 0 30 2 *", are rejected.
+func ParseCron(expression string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(expression)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+	}
+
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never fires", expression)
+	}
+
+	return schedule, nil
+}
+
+// Crontab returns the cron expression of the watched directory, prefixed
+// with its timezone unless the expression already sets one
+func (d WatchedDirectory) Crontab() string {
+	if d.Timezone == "" || strings.HasPrefix(d.Cron, "TZ=") || strings.HasPrefix(d.Cron, "CRON_TZ=") {
+		return d.Cron
+	}
+
+	return "CRON_TZ=" + d.Timezone + " " + d.Cron
+}
+
+// Location returns the timezone of the watched directory, the local one
+// of the host when unset
+func (d WatchedDirectory) Location() (*time.Location, error) {
+	if d.Timezone == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(d.Timezone)
+}
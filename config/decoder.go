@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Decoder parses the content of a configuration file into a generic
+// document, made of the values JSON decodes into with numbers kept as
+// json.Number
+type Decoder func(content []byte) (any, error)
+
+// decoders are the decoders of the configuration formats, by file
+// extension
+var decoders = map[string]Decoder{
+	".json": DecodeJSON,
+	".yaml": DecodeYAML,
+	".yml":  DecodeYAML,
+	".toml": DecodeTOML,
+}
+
+// RegisterDecoder makes the configuration files with a given extension,
+// such as ".json", load through a decoder
+func RegisterDecoder(extension string, decoder Decoder) {
+	decoders[strings.ToLower(extension)] = decoder
+}
+
+// decoderFor returns the decoder of a configuration file, from its
+// extension. Files without one are read as JSON.
+func decoderFor(path string) (Decoder, error) {
+	extension := strings.ToLower(filepath.Ext(path))
+
+	if extension == "" {
+		return DecodeJSON, nil
+	}
+
+	decoder, ok := decoders[extension]
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported configuration format %q", extension)
+	}
+
+	return decoder, nil
+}
+
+// DecodeJSON parses a JSON configuration
+func DecodeJSON(content []byte) (any, error) {
+	var document any
+
+	if err := unmarshalStrict(content, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// DecodeYAML parses a YAML configuration
+func DecodeYAML(content []byte) (any, error) {
+	var document any
+
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	return normalize(document)
+}
+
+// DecodeTOML parses a TOML configuration
+func DecodeTOML(content []byte) (any, error) {
+	var document map[string]any
+
+	if err := toml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	return normalize(document)
+}
+
+// normalize turns a document read from another format than JSON into the
+// values JSON decodes into, so that its fields are read the same way
+func normalize(document any) (any, error) {
+	content, err := json.Marshal(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeJSON(content)
+}
+
+// decode decodes a document into a Config or a fragment. It is decoded
+// strictly: every unknown field and value of the wrong kind is reported.
+func decode(document any, target any) error {
+	errs := problems{}
+	document = checkDocument(&errs, "", document, reflect.TypeOf(target))
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	content, err := json.Marshal(document)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, target)
+}
+
+// unmarshalStrict is json.Unmarshal rejecting unknown fields and keeping
+// numbers as json.Number, so that none loses precision
+func unmarshalStrict(content []byte, value any) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+
+	if decoder.More() {
+		return errors.New("invalid character after top-level value")
+	}
+
+	return nil
+}
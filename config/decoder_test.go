@@ -0,0 +1,81 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLoadFormats(t *testing.T) {
+	expected := Config{
+		Cron:     "0 3 * * *",
+		Timezone: "Europe/Berlin",
+		Windows: []Window{{
+			Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			Start: time.Hour,
+			End:   5 * time.Hour,
+		}},
+		Lock: &Lock{Dir: "/locks", TTL: 30},
+		WatchedDirectories: []WatchedDirectory{
+			{Path: "/var/log/app", Age: Days(7), Recursive: true, Exclude: []string{"**/*.keep"}, MaxTotalSize: 2_000_000_000},
+			{Path: "/var/backups", Cron: "30 2 * * *", Age: Days(0.5), Watermark: &Watermark{
+				Low:      Threshold{Percent: 10},
+				High:     Threshold{Percent: 20},
+				Interval: 120,
+			}},
+		},
+	}
+
+	for _, path := range []string{"testdata/config_formats.json", "testdata/config_formats.yaml", "testdata/config_formats.toml"} {
+		config, err := New(path).Load()
+
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, config, path)
+	}
+}
+
+func TestLoadUnsupportedFormat(t *testing.T) {
+	_, err := New("testdata/config.ini").Load()
+
+	assert.ErrorContains(t, err, `unsupported configuration format ".ini"`)
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	RegisterDecoder(".JSN", func(content []byte) (any, error) {
+		return map[string]any{"cron": string(content)}, nil
+	})
+	defer delete(decoders, ".jsn")
+
+	decoder, err := decoderFor("/etc/fileman/config.jsn")
+	assert.NoError(t, err)
+
+	config := Config{}
+	assert.NoError(t, decodeWith(decoder, "* * * * *", &config))
+	assert.Equal(t, "* * * * *", config.Cron)
+
+	decoder, err = decoderFor("config")
+	assert.NoError(t, err)
+	assert.NoError(t, decodeWith(decoder, `{"cron": "0 * * * *"}`, &config))
+	assert.Equal(t, "0 * * * *", config.Cron)
+}
+
+func TestDecodeInvalidDocuments(t *testing.T) {
+	assert.Error(t, decodeWith(DecodeYAML, "cron: [\n", &Config{}))
+	assert.Error(t, decodeWith(DecodeTOML, "cron = \n", &Config{}))
+	assert.Error(t, decodeWith(DecodeYAML, "watchedDirectories: foo\n", &Config{}))
+
+	config := Config{}
+	assert.NoError(t, decodeWith(DecodeYAML, "# nothing yet\n", &config))
+	assert.Equal(t, Config{}, config)
+}
+
+// decodeWith parses a configuration with a decoder and decodes it
+func decodeWith(decoder Decoder, content string, config *Config) error {
+	document, err := decoder([]byte(content))
+
+	if err != nil {
+		return err
+	}
+
+	return decode(document, config)
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Day is the length of a day in durations, which ignores daylight saving
+// time changes
+const Day = 24 * time.Hour
+
+// durationUnits are the units of a duration string, longest first
+var durationUnits = []struct {
+	name   string
+	length time.Duration
+}{
+	{"w", 7 * Day},
+	{"d", Day},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+}
+
+// Duration is an age threshold. It is written either as a number of days,
+// fractions included, or as a string of amounts with units such as "36h",
+// "7d", "2w", "1d12h" or "90m".
+type Duration time.Duration
+
+// Days returns the duration of a number of days
+func Days(days float64) Duration {
+	return Duration(days * float64(Day))
+}
+
+// ParseDuration parses a duration string such as "1d12h", made of amounts
+// in weeks (w), days (d), hours (h), minutes (m) or seconds (s). A bare
+// number, such as one read from an environment variable, is a number of
+// days, as it is in JSON.
+func ParseDuration(value string) (Duration, error) {
+	remaining := strings.TrimSpace(value)
+	sign := Duration(1)
+
+	if strings.HasPrefix(remaining, "-") {
+		sign = -1
+		remaining = remaining[1:]
+	}
+
+	if remaining == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	if strings.IndexFunc(remaining, isNotAmount) == -1 {
+		remaining += "d"
+	}
+
+	duration := Duration(0)
+
+	for remaining != "" {
+		split := strings.IndexFunc(remaining, isNotAmount)
+
+		if split <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+
+		amount, err := strconv.ParseFloat(remaining[:split], 64)
+
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+
+		remaining = remaining[split:]
+		found := false
+
+		for _, unit := range durationUnits {
+			if strings.HasPrefix(remaining, unit.name) {
+				length := amount * float64(unit.length)
+
+				if length >= math.MaxInt64 || Duration(length) > math.MaxInt64-duration {
+					return 0, fmt.Errorf("duration %q is too long", value)
+				}
+
+				duration += Duration(length)
+				remaining = remaining[len(unit.name):]
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("invalid duration unit in %q, expected w, d, h, m or s", value)
+		}
+	}
+
+	return sign * duration, nil
+}
+
+// isNotAmount tells whether a rune ends the amount of a duration or size
+func isNotAmount(r rune) bool {
+	return (r < '0' || r > '9') && r != '.'
+}
+
+// String returns the duration in days, hours, minutes and seconds, such as
+// "1d12h", the way it can be written in a configuration
+func (d Duration) String() string {
+	if d == 0 {
+		return "0s"
+	}
+
+	if time.Duration(d)%time.Second != 0 {
+		return time.Duration(d).String()
+	}
+
+	builder := strings.Builder{}
+	remaining := time.Duration(d)
+
+	if remaining < 0 {
+		builder.WriteString("-")
+		remaining = -remaining
+	}
+
+	for _, unit := range durationUnits[1:] {
+		if amount := remaining / unit.length; amount > 0 {
+			fmt.Fprintf(&builder, "%d%s", amount, unit.name)
+			remaining -= amount * unit.length
+		}
+	}
+
+	return builder.String()
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var days float64
+
+	if err := json.Unmarshal(data, &days); err == nil {
+		if math.Abs(days*float64(Day)) >= math.MaxInt64 {
+			return fmt.Errorf("duration of %s days is too long", data)
+		}
+
+		*d = Days(days)
+		return nil
+	}
+
+	var value string
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a number of days or a string, got %s", data)
+	}
+
+	duration, err := ParseDuration(value)
+
+	if err != nil {
+		return err
+	}
+
+	*d = duration
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"36h":   36 * time.Hour,
+		"7d":    7 * Day,
+		"2w":    14 * Day,
+		"1d12h": 36 * time.Hour,
+		"90m":   90 * time.Minute,
+		"1.5d":  36 * time.Hour,
+		"45s":   45 * time.Second,
+		" 1h ":  time.Hour,
+		"-1d":   -Day,
+		"7":     7 * Day,
+		"0.5":   12 * time.Hour,
+		" -2 ":  -2 * Day,
+	}
+
+	for value, expected := range tests {
+		duration, err := ParseDuration(value)
+
+		assert.NoError(t, err, value)
+		assert.Equal(t, Duration(expected), duration, value)
+	}
+
+	for _, value := range []string{"", "-", ".", "d", "7x", "1d2", "1..5d", "7 d", "1h-1m"} {
+		_, err := ParseDuration(value)
+		assert.Error(t, err, value)
+	}
+
+	for _, value := range []string{"99999999999w", "-99999999999w", "99999999999", "15251w", "106751d106751d", "1000000000000000000000d"} {
+		_, err := ParseDuration(value)
+		assert.ErrorContains(t, err, "is too long", value)
+	}
+}
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := map[string]Duration{
+		`7`:       Days(7),
+		`0.25`:    Duration(6 * time.Hour),
+		`"36h"`:   Duration(36 * time.Hour),
+		`"1d12h"`: Days(1.5),
+		`"7"`:     Days(7),
+	}
+
+	for data, expected := range tests {
+		var duration Duration
+
+		assert.NoError(t, json.Unmarshal([]byte(data), &duration), data)
+		assert.Equal(t, expected, duration, data)
+	}
+
+	var duration Duration
+	assert.ErrorContains(t, json.Unmarshal([]byte(`"a week"`), &duration), `invalid duration "a week"`)
+	assert.ErrorContains(t, json.Unmarshal([]byte(`"7y"`), &duration), `invalid duration unit in "7y"`)
+	assert.ErrorContains(t, json.Unmarshal([]byte(`true`), &duration), "duration must be a number of days or a string")
+	assert.ErrorContains(t, json.Unmarshal([]byte(`1e10`), &duration), "duration of 1e10 days is too long")
+}
+
+func TestDurationString(t *testing.T) {
+	assert.Equal(t, "0s", Duration(0).String())
+	assert.Equal(t, "1d12h", Days(1.5).String())
+	assert.Equal(t, "14d", Days(14).String())
+	assert.Equal(t, "1h30m15s", Duration(90*time.Minute+15*time.Second).String())
+	assert.Equal(t, "-6h", Days(-0.25).String())
+	assert.Equal(t, "1.5s", Duration(1500*time.Millisecond).String())
+
+	content, err := json.Marshal(Days(2.5))
+	assert.NoError(t, err)
+	assert.Equal(t, `"2d12h"`, string(content))
+
+	var duration Duration
+	assert.NoError(t, json.Unmarshal(content, &duration))
+	assert.Equal(t, Days(2.5), duration)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fileman/fs"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// dropInDir is the directory, next to the configuration file, whose
+// fragments are merged into the configuration
+const dropInDir = "conf.d"
+
+// fragment is a configuration file merged into the main one, adding
+// watched directories to it. The global settings are left to the main one.
+type fragment struct {
+	WatchedDirectories []WatchedDirectory
+}
+
+// origin is where a watched directory is defined: the file and its index
+// among the watched directories of the file
+type origin struct {
+	file  string
+	index int
+}
+
+// fragments returns the fragments merged into a configuration, in the
+// order they are merged in: the files matching each include glob in turn,
+// sorted by path, then the ones of the conf.d directory, sorted by name.
+// Relative globs are matched from the directory of the configuration
+// file. In conf.d, hidden files and files of unknown formats are left out,
+// such as editor backups. Each file is merged once.
+func (h ConfigHandler) fragments(config Config) ([]string, error) {
+	dir := filepath.Dir(h.config)
+	merged := map[string]bool{filepath.Clean(h.config): true}
+	files := make([]string, 0)
+	errs := problems{}
+
+	add := func(file string) {
+		if !merged[filepath.Clean(file)] {
+			merged[filepath.Clean(file)] = true
+			files = append(files, file)
+		}
+	}
+
+	for i, pattern := range config.Include {
+		matches, err := doublestar.FilepathGlob(includePattern(dir, pattern), doublestar.WithFilesOnly())
+
+		if err != nil {
+			errs.add(fmt.Sprintf("include[%d]", i), fmt.Errorf("invalid pattern %q: %w", pattern, err))
+			continue
+		}
+
+		slices.Sort(matches)
+
+		for _, match := range matches {
+			add(match)
+		}
+	}
+
+	fileSystem := fs.FS{}
+	entries, err := fileSystem.ReadDir(filepath.Join(dir, dropInDir))
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, dropInDir, entry.Name())
+
+		if !isDropIn(entry.Name()) {
+			continue
+		}
+
+		if info, err := fileSystem.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+
+		add(path)
+	}
+
+	return files, errors.Join(errs...)
+}
+
+// isDropIn tells whether a file of the conf.d directory is a fragment,
+// rather than a hidden file or one of an unknown format
+func isDropIn(name string) bool {
+	if strings.HasPrefix(name, ".") || filepath.Ext(name) == "" {
+		return false
+	}
+
+	_, err := decoderFor(name)
+
+	return err == nil
+}
+
+// includePattern returns an include glob as matched from the directory of
+// the configuration file
+func includePattern(dir string, pattern string) string {
+	if filepath.IsAbs(pattern) {
+		return pattern
+	}
+
+	return filepath.Join(dir, pattern)
+}
+
+// Dirs returns the directories the files of a configuration are read
+// from, to watch for changes: the one of the configuration file, its
+// conf.d directory, the base directories of its include globs and the
+// directories of the files they match
+func (h ConfigHandler) Dirs(config Config) []string {
+	dir := filepath.Dir(h.config)
+	dirs := []string{dir, filepath.Join(dir, dropInDir)}
+
+	for _, pattern := range config.Include {
+		base, _ := doublestar.SplitPattern(filepath.ToSlash(includePattern(dir, pattern)))
+		dirs = append(dirs, filepath.FromSlash(base))
+	}
+
+	files, _ := h.fragments(config)
+
+	for _, file := range files {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+
+	for i := range dirs {
+		dirs[i] = filepath.Clean(dirs[i])
+	}
+
+	slices.Sort(dirs)
+
+	return slices.Compact(dirs)
+}
+
+// IsSource tells whether a changed file is one a configuration is read
+// from: the configuration file, a fragment of its conf.d directory, a file
+// matching one of its include globs, or an entry of a Kubernetes
+// ConfigMap volume, named ..data and the like, swapped to update its files
+// at once. Other files, such as the state file or logs, are left out.
+func (h ConfigHandler) IsSource(config Config, path string) bool {
+	dir := filepath.Dir(h.config)
+	path = filepath.Clean(path)
+
+	if path == filepath.Clean(h.config) || strings.HasPrefix(filepath.Base(path), "..") {
+		return true
+	}
+
+	if filepath.Dir(path) == filepath.Join(dir, dropInDir) && isDropIn(filepath.Base(path)) {
+		return true
+	}
+
+	for _, pattern := range config.Include {
+		if matched, _ := doublestar.PathMatch(includePattern(dir, pattern), path); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfigFiles writes configuration files, by path relative to a
+// directory
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestLoadMergesFragments(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"config.yaml":           "cron: \"0 * * * *\"\ninclude: [\"teams/*.yaml\", \"extra/**/*.json\"]\nwatchedDirectories:\n  - path: /srv/main\n",
+		"teams/b.yaml":          "watchedDirectories:\n  - path: /srv/b\n",
+		"teams/a.yaml":          "watchedDirectories:\n  - path: /srv/a1\n  - path: /srv/a2\n",
+		"teams/ignored.toml":    "[[watchedDirectories]]\npath = \"/srv/ignored\"\n",
+		"extra/nested/c.json":   `{"watchedDirectories": [{"path": "/srv/c"}]}`,
+		"conf.d/20-e.json":      `{"watchedDirectories": [{"path": "/srv/e"}]}`,
+		"conf.d/10-d.toml":      "[[watchedDirectories]]\npath = \"/srv/d\"\n",
+		"conf.d/.hidden.yaml":   "watchedDirectories:\n  - path: /srv/hidden\n",
+		"conf.d/README.md":      "Drop a file per team here",
+		"conf.d/notes":          "not a fragment",
+		"conf.d/20-e.json.bak":  "backup",
+		"conf.d/dir.yaml/x.yml": "watchedDirectories: []\n",
+	})
+
+	config, err := New(filepath.Join(dir, "config.yaml")).Load()
+
+	assert.NoError(t, err)
+
+	paths := make([]string, 0)
+	for _, directory := range config.Directories() {
+		paths = append(paths, directory.Path)
+		assert.Equal(t, "0 * * * *", directory.Cron, directory.Path)
+	}
+
+	assert.Equal(t, []string{"/srv/main", "/srv/a1", "/srv/a2", "/srv/b", "/srv/c", "/srv/d", "/srv/e"}, paths)
+	assert.Equal(t, origin{file: filepath.Join(dir, "teams/a.yaml"), index: 1}, config.origins[2])
+}
+
+func TestLoadMergesEachFragmentOnce(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"config.json":       `{"cron": "0 * * * *", "include": ["*.json", "conf.d/*.json"], "watchedDirectories": [{"path": "/srv/main"}]}`,
+		"conf.d/team.json":  `{"watchedDirectories": [{"path": "/srv/team"}]}`,
+		"other.json":        `{"watchedDirectories": [{"path": "/srv/other"}]}`,
+		"unmatched/x.json":  `{"watchedDirectories": [{"path": "/srv/x"}]}`,
+		"conf.d/empty.json": `{}`,
+	})
+
+	config, err := New(filepath.Join(dir, "config.json")).Load()
+
+	assert.NoError(t, err)
+	assert.Len(t, config.WatchedDirectories, 3)
+	assert.Equal(t, "/srv/team", config.WatchedDirectories[2].Path)
+}
+
+func TestLoadReportsProblemsWithTheirFile(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"config.json":        `{"cron": "0 * * * *", "watchedDirectories": [{"path": "/srv/logs"}]}`,
+		"conf.d/a.yaml":      "watchedDirectories:\n  - path: /srv/tmp\n  - path: /srv/logs\n",
+		"conf.d/b.yaml":      "watchedDirectories:\n  - path: /srv/tmp/\n    age: -1\n",
+		"conf.d/global.yaml": "cron: \"* * * * *\"\n",
+	})
+
+	configFile := filepath.Join(dir, "config.json")
+	_, err := New(configFile).Load()
+
+	assert.EqualError(t, err, filepath.Join(dir, "conf.d/global.yaml")+": cron: unknown field")
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "conf.d/global.yaml")))
+	_, err = New(configFile).Load()
+
+	a := filepath.Join(dir, "conf.d/a.yaml")
+	b := filepath.Join(dir, "conf.d/b.yaml")
+
+	if assert.Error(t, err) {
+		assert.Equal(t, b+": watchedDirectories[0].age: must not be negative, got -1d\n"+
+			a+": watchedDirectories[1].path: /srv/logs is already watched by watchedDirectories[0] of "+configFile+"\n"+
+			b+": watchedDirectories[0].path: /srv/tmp/ is already watched by watchedDirectories[0] of "+a, err.Error())
+	}
+}
+
+func TestLoadReportsInvalidIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"config.json": `{"cron": "0 * * * *", "include": ["teams/[a-"], "watchedDirectories": []}`,
+	})
+
+	_, err := New(filepath.Join(dir, "config.json")).Load()
+
+	assert.ErrorContains(t, err, `include[0]: invalid pattern "teams/[a-"`)
+}
+
+func TestDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"teams/nested/a.yaml": "watchedDirectories: []\n",
+	})
+
+	configHandler := New(filepath.Join(dir, "config.json"))
+	config := Config{Include: []string{"teams/**/*.yaml", "/etc/fileman/*.json", "teams/*.toml"}}
+
+	assert.Equal(t, []string{
+		"/etc/fileman",
+		dir,
+		filepath.Join(dir, "conf.d"),
+		filepath.Join(dir, "teams"),
+		filepath.Join(dir, "teams/nested"),
+	}, configHandler.Dirs(config))
+}
+
+func TestIsSource(t *testing.T) {
+	configHandler := New("/etc/fileman/fileman")
+	config := Config{Include: []string{"teams/*.yaml", "/srv/teams/**/*.toml"}}
+
+	for _, path := range []string{"/etc/fileman/fileman", "/etc/fileman/conf.d/team.YAML", "/etc/fileman/teams/a.yaml", "/srv/teams/a.toml", "/srv/teams/b/c.toml", "/etc/fileman/..data", "/etc/fileman/..2024_01_01_00_00_00.123"} {
+		assert.True(t, configHandler.IsSource(config, path), path)
+	}
+
+	for _, path := range []string{"/etc/fileman/fileman.log", "/etc/fileman/state.json", "/etc/fileman/teams/a.json", "/etc/fileman/conf.d/.team.yaml.swp", "/etc/fileman/conf.d/team.yaml~", "/etc/fileman/conf.d/README", "/etc/fileman/conf.d/nested/team.yaml"} {
+		assert.False(t, configHandler.IsSource(config, path), path)
+	}
+
+	assert.False(t, configHandler.IsSource(Config{}, "/etc/fileman/teams/a.yaml"))
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// secretPrefix marks the values read from a file, such as a mounted
+// secret
+const secretPrefix = "file:"
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolator resolves the references in the string values of a
+// configuration: ${VAR} and ${VAR:-default} are replaced with environment
+// variables, $$ with a single $, and a value starting with file: with the
+// content of the file it names, once its references are resolved
+type interpolator struct {
+	lookupEnv func(name string) (string, bool)
+	readFile  func(path string) ([]byte, error)
+	dir       string
+}
+
+// resolvedString is a string value holding references, once resolved. As
+// environment variables and files hold strings, it may also set a boolean
+// or numeric field, converted when the document is checked.
+type resolvedString string
+
+// document resolves the references in the string values of a document,
+// keys aside, reporting every one that can't be resolved
+func (i interpolator) document(errs *problems, path string, document any) any {
+	switch value := document.(type) {
+	case string:
+		resolved, err := i.resolve(value)
+		errs.add(path, err)
+
+		if resolved != value {
+			return resolvedString(resolved)
+		}
+
+		return resolved
+	case map[string]any:
+		for key, element := range value {
+			value[key] = i.document(errs, field(path, key), element)
+		}
+	case []any:
+		for index, element := range value {
+			value[index] = i.document(errs, fmt.Sprintf("%s[%d]", path, index), element)
+		}
+	}
+
+	return document
+}
+
+// resolve resolves the references in a value. Files are read from the
+// directory of the configuration unless their path is absolute, and their
+// trailing newlines dropped.
+func (i interpolator) resolve(value string) (string, error) {
+	expanded, err := i.expand(value)
+
+	if err != nil || !strings.HasPrefix(expanded, secretPrefix) {
+		return expanded, err
+	}
+
+	path := strings.TrimPrefix(expanded, secretPrefix)
+
+	if path == "" {
+		return "", errors.New("file: reference without a path")
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(i.dir, path)
+	}
+
+	content, err := i.readFile(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// expand replaces the environment variables referenced in a value. A
+// default is used when the variable is unset or empty, and a variable
+// without one must be set.
+func (i interpolator) expand(value string) (string, error) {
+	builder := strings.Builder{}
+	remaining := value
+
+	for {
+		start := strings.IndexByte(remaining, '$')
+
+		if start < 0 || start == len(remaining)-1 {
+			builder.WriteString(remaining)
+			return builder.String(), nil
+		}
+
+		builder.WriteString(remaining[:start])
+		remaining = remaining[start:]
+
+		switch remaining[1] {
+		case '$':
+			builder.WriteByte('$')
+			remaining = remaining[2:]
+			continue
+		case '{':
+		default:
+			builder.WriteByte('$')
+			remaining = remaining[1:]
+			continue
+		}
+
+		end := strings.IndexByte(remaining, '}')
+
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", value)
+		}
+
+		name, fallback, hasDefault := strings.Cut(remaining[2:end], ":-")
+		remaining = remaining[end+1:]
+
+		if !variableName.MatchString(name) {
+			return "", fmt.Errorf("invalid environment variable name %q in %q", name, value)
+		}
+
+		variable, ok := i.lookupEnv(name)
+
+		switch {
+		case ok && variable != "":
+			builder.WriteString(variable)
+		case hasDefault:
+			builder.WriteString(fallback)
+		case ok:
+		default:
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpolatorExpand(t *testing.T) {
+	resolver := interpolator{lookupEnv: func(name string) (string, bool) {
+		value, ok := map[string]string{"ENV": "prod", "EMPTY": ""}[name]
+		return value, ok
+	}}
+
+	tests := map[string]string{
+		"/data/${ENV}/logs":         "/data/prod/logs",
+		"${ENV}-${ENV}":             "prod-prod",
+		"${MISSING:-/tmp}":          "/tmp",
+		"${EMPTY:-fallback}":        "fallback",
+		"${EMPTY}":                  "",
+		"${ENV:-staging}":           "prod",
+		"${MISSING:-}":              "",
+		"price: $$5, cash $ and $x": "price: $5, cash $ and $x",
+		"$${ENV}":                   "${ENV}",
+		"trailing $":                "trailing $",
+	}
+
+	for value, expected := range tests {
+		expanded, err := resolver.expand(value)
+
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, expanded, value)
+	}
+
+	_, err := resolver.expand("/data/${MISSING}")
+	assert.EqualError(t, err, "environment variable MISSING is not set")
+
+	_, err = resolver.expand("/data/${ENV")
+	assert.EqualError(t, err, `unterminated reference in "/data/${ENV"`)
+
+	_, err = resolver.expand("${1ENV}")
+	assert.EqualError(t, err, `invalid environment variable name "1ENV" in "${1ENV}"`)
+}
+
+func TestInterpolatorReadsFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "token"), []byte("s3cr3t\n"), 0o600))
+
+	resolver := interpolator{
+		lookupEnv: func(name string) (string, bool) { return dir, name == "SECRETS" },
+		readFile:  os.ReadFile,
+		dir:       dir,
+	}
+
+	for _, value := range []string{"file:" + filepath.Join(dir, "token"), "file:${SECRETS}/token", "file:token"} {
+		resolved, err := resolver.resolve(value)
+
+		assert.NoError(t, err, value)
+		assert.Equal(t, "s3cr3t", resolved, value)
+	}
+
+	resolved, err := resolver.resolve("profile:${SECRETS}")
+	assert.NoError(t, err)
+	assert.Equal(t, "profile:"+dir, resolved)
+
+	_, err = resolver.resolve("file:missing")
+	assert.ErrorContains(t, err, "no such file")
+
+	_, err = resolver.resolve("file:")
+	assert.EqualError(t, err, "file: reference without a path")
+}
+
+func TestInterpolatorReportsEveryReference(t *testing.T) {
+	resolver := interpolator{lookupEnv: func(string) (string, bool) { return "", false }}
+	errs := problems{}
+
+	document := resolver.document(&errs, "", map[string]any{
+		"cron": "${CRON:-0 * * * *}",
+		"watchedDirectories": []any{
+			map[string]any{"path": "${LOGS}", "recursive": true, "${KEY}": "kept"},
+			map[string]any{"path": "/tmp", "age": "${AGE}"},
+		},
+	})
+
+	assert.Equal(t, resolvedString("0 * * * *"), document.(map[string]any)["cron"])
+	assert.Contains(t, document.(map[string]any)["watchedDirectories"].([]any)[0], "${KEY}")
+
+	if assert.Len(t, errs, 2) {
+		assert.ElementsMatch(t, []string{
+			"watchedDirectories[0].path: environment variable LOGS is not set",
+			"watchedDirectories[1].age: environment variable AGE is not set",
+		}, []string{errs[0].Error(), errs[1].Error()})
+	}
+}
+
+func TestLoadResolvesReferences(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := "cron: \"${FILEMAN_CRON:-0 3 * * *}\"\n" +
+		"watchedDirectories:\n" +
+		"  - path: ${FILEMAN_ROOT}/logs\n" +
+		"    age: file:age\n"
+
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "age"), []byte("36h\n"), 0o600))
+	t.Setenv("FILEMAN_ROOT", "/srv")
+
+	config, err := New(path).Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "0 3 * * *", config.Cron)
+	assert.Equal(t, []WatchedDirectory{{Path: "/srv/logs", Age: Days(1.5)}}, config.WatchedDirectories)
+
+	assert.Nil(t, os.Unsetenv("FILEMAN_ROOT"))
+
+	_, err = New(path).Load()
+	assert.EqualError(t, err, "watchedDirectories[0].path: environment variable FILEMAN_ROOT is not set")
+}
+
+func TestLoadResolvesNumbersFromTheEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"cron": "0 3 * * *", "watchedDirectories": [{"path": "/srv/logs", "age": "${FILEMAN_AGE}", ` +
+		`"maxTotalSize": "${FILEMAN_SIZE}", "watermark": {"low": "${FILEMAN_LOW}"}}]}`
+
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+	t.Setenv("FILEMAN_AGE", "7")
+	t.Setenv("FILEMAN_SIZE", "1048576")
+	t.Setenv("FILEMAN_LOW", "4096")
+
+	config, err := New(path).Load()
+
+	assert.NoError(t, err)
+	assert.Equal(t, Days(7), config.WatchedDirectories[0].Age)
+	assert.Equal(t, Size(1048576), config.WatchedDirectories[0].MaxTotalSize)
+	assert.Equal(t, Threshold{Bytes: 4096}, config.WatchedDirectories[0].Watermark.Low)
+}
+
+func TestLoadResolvesBooleansAndIntegers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"cron": "0 3 * * *", "dryRun": "${FILEMAN_DRY:-true}", "gracePeriod": "${FILEMAN_GRACE}", ` +
+		`"watchedDirectories": [{"path": "/srv/logs", "recursive": "${FILEMAN_RECURSIVE}", "keepLatest": "${FILEMAN_KEEP:-3}"}]}`
+
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+	t.Setenv("FILEMAN_GRACE", "30")
+	t.Setenv("FILEMAN_RECURSIVE", "false")
+
+	config, err := New(path).Load()
+
+	assert.NoError(t, err)
+	assert.True(t, config.DryRun)
+	assert.Equal(t, 30, config.GracePeriod)
+	assert.False(t, config.WatchedDirectories[0].Recursive)
+	assert.Equal(t, 3, config.WatchedDirectories[0].KeepLatest)
+
+	t.Setenv("FILEMAN_RECURSIVE", "sometimes")
+	t.Setenv("FILEMAN_GRACE", "30s")
+
+	_, err = New(path).Load()
+	assert.ErrorContains(t, err, `gracePeriod: expected an integer, got 30s`)
+	assert.ErrorContains(t, err, `watchedDirectories[0].recursive: expected a boolean, got "sometimes"`)
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"cron": "0 3 * * *", "dryRun": "true", "watchedDirectories": []}`), 0o644))
+
+	_, err = New(path).Load()
+	assert.ErrorContains(t, err, "dryRun: expected a boolean, got a string")
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// checkDocument checks a document decoded from JSON, with its numbers as
+// json.Number, against the type it is to be decoded into: objects may only
+// hold known fields, and every value must be of the kind of its field.
+// Values of types with their own unmarshaller are decoded to check them.
+// It returns the document with the resolved strings converted to the kind
+// of their field, such as a boolean read from an environment variable.
+func checkDocument(errs *problems, path string, document any, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if document == nil {
+		return document
+	}
+
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		content, err := json.Marshal(document)
+
+		if err == nil {
+			err = reflect.New(t).Interface().(json.Unmarshaler).UnmarshalJSON(content)
+		}
+
+		errs.add(path, err)
+		return document
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := document.(map[string]any)
+
+		if !ok {
+			errs.add(path, mismatch("an object", document))
+			return document
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			structField, ok := fieldByKey(t, key)
+
+			if !ok {
+				errs.add(field(path, key), errors.New("unknown field"))
+				continue
+			}
+
+			object[key] = checkDocument(errs, field(path, key), object[key], structField.Type)
+		}
+	case reflect.Slice:
+		array, ok := document.([]any)
+
+		if !ok {
+			errs.add(path, mismatch("an array", document))
+			return document
+		}
+
+		for i, element := range array {
+			array[i] = checkDocument(errs, fmt.Sprintf("%s[%d]", path, i), element, t.Elem())
+		}
+	case reflect.String:
+		switch value := document.(type) {
+		case string:
+		case resolvedString:
+			return string(value)
+		default:
+			errs.add(path, mismatch("a string", document))
+		}
+	case reflect.Bool:
+		switch value := document.(type) {
+		case bool:
+		case resolvedString:
+			boolean, err := strconv.ParseBool(string(value))
+
+			if err != nil {
+				errs.add(path, fmt.Errorf("expected a boolean, got %q", string(value)))
+				return document
+			}
+
+			return boolean
+		default:
+			errs.add(path, mismatch("a boolean", document))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := document.(json.Number)
+
+		if value, resolved := document.(resolvedString); resolved {
+			number, ok = json.Number(strings.TrimSpace(string(value))), true
+		}
+
+		if !ok {
+			errs.add(path, mismatch("an integer", document))
+		} else if _, err := number.Int64(); err != nil {
+			errs.add(path, fmt.Errorf("expected an integer, got %s", number))
+		} else {
+			return number
+		}
+	case reflect.Float32, reflect.Float64:
+		number, ok := document.(json.Number)
+
+		if value, resolved := document.(resolvedString); resolved {
+			number, ok = json.Number(strings.TrimSpace(string(value))), true
+		}
+
+		if !ok {
+			errs.add(path, mismatch("a number", document))
+		} else if _, err := number.Float64(); err != nil {
+			errs.add(path, fmt.Errorf("expected a number, got %s", number))
+		} else {
+			return number
+		}
+	}
+
+	return document
+}
+
+// fieldByKey returns the field of a struct a key of a JSON object is
+// decoded into, matching its name regardless of case as encoding/json does
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		structField := t.Field(i)
+		name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+
+		if name == "" {
+			name = structField.Name
+		}
+
+		if structField.IsExported() && name != "-" && strings.EqualFold(name, key) {
+			return structField, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// mismatch reports a value of the wrong kind
+func mismatch(expected string, value any) error {
+	kind := "null"
+
+	switch value.(type) {
+	case string, resolvedString:
+		kind = "a string"
+	case json.Number:
+		kind = "a number"
+	case bool:
+		kind = "a boolean"
+	case []any:
+		kind = "an array"
+	case map[string]any:
+		kind = "an object"
+	}
+
+	return fmt.Errorf("expected %s, got %s", expected, kind)
+}
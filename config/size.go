@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Size is an amount of bytes, written in the configuration either as a
+// plain number of bytes or as a string with a decimal (KB, MB, GB, TB) or
+// binary (KiB, MiB, GiB, TiB) unit, e.g. "20GiB" or "1.5 GB".
+type Size int64
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// ParseSize parses a size such as "20GiB" into a number of bytes
+func ParseSize(value string) (Size, error) {
+	value = strings.TrimSpace(value)
+	split := strings.IndexFunc(value, isNotAmount)
+
+	if split == -1 {
+		split = len(value)
+	}
+
+	amount, err := strconv.ParseFloat(value[:split], 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	multiplier, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(value[split:]))]
+
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", value)
+	}
+
+	return Size(amount * multiplier), nil
+}
+
+func (s *Size) UnmarshalJSON(data []byte) error {
+	var bytes int64
+
+	if err := json.Unmarshal(data, &bytes); err == nil {
+		*s = Size(bytes)
+		return nil
+	}
+
+	var value string
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("size must be a number of bytes or a string, got %s", data)
+	}
+
+	size, err := ParseSize(value)
+
+	if err != nil {
+		return err
+	}
+
+	*s = size
+
+	return nil
+}
+
+// Threshold is an amount of disk space, written either as a percentage of
+// the filesystem size, e.g. "10%", or as a Size, e.g. "5GB".
+type Threshold struct {
+	Percent float64
+	Bytes   Size
+}
+
+// ParseThreshold parses a threshold such as "10%" or "5GB"
+func ParseThreshold(value string) (Threshold, error) {
+	value = strings.TrimSpace(value)
+
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		amount, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+
+		if err != nil || amount < 0 || amount > 100 {
+			return Threshold{}, fmt.Errorf("invalid percentage %q", value)
+		}
+
+		return Threshold{Percent: amount}, nil
+	}
+
+	size, err := ParseSize(value)
+
+	if err != nil {
+		return Threshold{}, err
+	}
+
+	return Threshold{Bytes: size}, nil
+}
+
+// Of returns the threshold in bytes for a filesystem of the given size
+func (t Threshold) Of(total uint64) uint64 {
+	if t.Percent > 0 {
+		return uint64(float64(total) * t.Percent / 100)
+	}
+
+	return uint64(t.Bytes)
+}
+
+// Below tells whether a threshold is below another of the same unit, both
+// percentages or both sizes. An unset threshold is below none.
+func (t Threshold) Below(other Threshold) bool {
+	if t.Percent > 0 && other.Percent > 0 {
+		return t.Percent < other.Percent
+	}
+
+	return t.Bytes > 0 && other.Bytes > 0 && t.Bytes < other.Bytes
+}
+
+// IsZero tells whether the threshold was left unset
+func (t Threshold) IsZero() bool {
+	return t.Percent == 0 && t.Bytes == 0
+}
+
+func (t *Threshold) UnmarshalJSON(data []byte) error {
+	var size Size
+
+	if err := json.Unmarshal(data, &size); err == nil {
+		*t = Threshold{Bytes: size}
+		return nil
+	}
+
+	var value string
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("threshold must be a percentage or a size, got %s", data)
+	}
+
+	threshold, err := ParseThreshold(value)
+
+	if err != nil {
+		return err
+	}
+
+	*t = threshold
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]Size{
+		"512":    512,
+		"512B":   512,
+		"20GiB":  20 << 30,
+		"1.5 GB": 1500000000,
+		"10mb":   10000000,
+		"2TiB":   2 << 40,
+	}
+
+	for value, expected := range cases {
+		size, err := ParseSize(value)
+
+		assert.Nil(t, err, value)
+		assert.Equal(t, expected, size, value)
+	}
+}
+
+func TestParseSizeInvalid(t *testing.T) {
+	for _, value := range []string{"", "GiB", "20 parsecs", "1.2.3GB"} {
+		_, err := ParseSize(value)
+
+		assert.Error(t, err, value)
+	}
+}
+
+func TestUnmarshalSize(t *testing.T) {
+	var sizes []Size
+
+	err := json.Unmarshal([]byte(`[1024, "1KiB"]`), &sizes)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Size{1024, 1024}, sizes)
+}
+
+func TestParseThreshold(t *testing.T) {
+	percent, err := ParseThreshold("10%")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Threshold{Percent: 10}, percent)
+	assert.Equal(t, uint64(100), percent.Of(1000))
+
+	bytes, err := ParseThreshold("5GB")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Threshold{Bytes: 5000000000}, bytes)
+	assert.Equal(t, uint64(5000000000), bytes.Of(1000))
+
+	assert.True(t, Threshold{Percent: 5}.Below(percent))
+	assert.False(t, percent.Below(Threshold{Percent: 5}))
+	assert.False(t, Threshold{}.Below(percent))
+	assert.False(t, Threshold{Bytes: 1}.Below(percent))
+
+	plain, err := ParseThreshold(" 1024 ")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Threshold{Bytes: 1024}, plain)
+}
+
+func TestParseThresholdInvalid(t *testing.T) {
+	for _, value := range []string{"", "%", "120%", "-1%", "ten percent"} {
+		_, err := ParseThreshold(value)
+
+		assert.Error(t, err, value)
+	}
+}
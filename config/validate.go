@@ -0,0 +1,437 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with a configuration, located by the JSON path
+// of the field at fault, such as watchedDirectories[1].age, and by the
+// fragment defining it, if any
+type FieldError struct {
+	File string
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	message := e.Err.Error()
+
+	if e.Path != "" {
+		message = e.Path + ": " + message
+	}
+
+	if e.File != "" {
+		message = e.File + ": " + message
+	}
+
+	return message
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// problems collects the problems found with a configuration
+type problems []error
+
+// add records a problem with the field at a given path, if any
+func (p *problems) add(path string, err error) {
+	if err != nil {
+		*p = append(*p, &FieldError{Path: path, Err: err})
+	}
+}
+
+// addFile records the problems found with a file, joined in an error
+func (p *problems) addFile(file string, err error) {
+	errs := []error{err}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+
+	for _, err := range errs {
+		fieldError, ok := err.(*FieldError)
+
+		if !ok {
+			fieldError = &FieldError{Err: err}
+		}
+
+		fieldError.File = file
+		*p = append(*p, fieldError)
+	}
+}
+
+// inFile sets the file of the problems recorded since a given count
+func (p problems) inFile(since int, file string) {
+	for _, err := range p[since:] {
+		if fieldError, ok := err.(*FieldError); ok {
+			fieldError.File = file
+		}
+	}
+}
+
+// field returns the path of a field of the object at a given path
+func field(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// Validate checks the whole configuration, returning every problem found
+// at once, joined together, each as a FieldError
+func (c Config) Validate() error {
+	errs := problems{}
+
+	validateSchedule(&errs, "", c.Cron, c.Timezone, c.OutsideWindow, c.Overlap)
+	validateLock(&errs, "lock", c.Lock)
+	validateAbsolute(&errs, "state", c.State)
+
+	for i, directory := range c.WatchedDirectories {
+		file, path := c.locate(i)
+		since := len(errs)
+
+		switch {
+		case directory.Path == "":
+			errs.add(field(path, "path"), errors.New("is required"))
+		case !filepath.IsAbs(directory.Path):
+			errs.add(field(path, "path"), fmt.Errorf("must be absolute, got %q", directory.Path))
+		}
+
+		if directory.Age < 0 {
+			errs.add(field(path, "age"), fmt.Errorf("must not be negative, got %s", directory.Age))
+		}
+
+		if directory.Trash != nil && directory.Trash.Age < 0 {
+			errs.add(field(path, "trash.age"), fmt.Errorf("must not be negative, got %s", directory.Trash.Age))
+		}
+
+		if directory.Trash != nil && validateAbsolute(&errs, field(path, "trash.path"), directory.Trash.Path) {
+			c.validateOutOfReach(&errs, field(path, "trash.path"), directory.Trash.Path)
+		}
+
+		if directory.Archive != nil && validateAbsolute(&errs, field(path, "archive.path"), directory.Archive.Path) {
+			c.validateOutOfReach(&errs, field(path, "archive.path"), directory.Archive.Path)
+		}
+
+		if directory.KeepLatest < 0 {
+			errs.add(field(path, "keepLatest"), fmt.Errorf("must not be negative, got %d", directory.KeepLatest))
+		}
+
+		if directory.MaxDepth < 0 {
+			errs.add(field(path, "maxDepth"), fmt.Errorf("must not be negative, got %d", directory.MaxDepth))
+		}
+
+		if directory.MaxTotalSize < 0 {
+			errs.add(field(path, "maxTotalSize"), fmt.Errorf("must not be negative, got %d", directory.MaxTotalSize))
+		}
+
+		if directory.Cron == "" && c.Cron == "" && directory.Trigger != "inotify" {
+			errs.add(field(path, "cron"), errors.New("is required as no global cron is set"))
+		}
+
+		validateRemoval(&errs, path, directory)
+		errs.add(field(path, "trigger"), validateTrigger(directory, cmp.Or(directory.Cron, c.Cron)))
+		validateLock(&errs, field(path, "lock"), directory.Lock)
+		validateSchedule(&errs, path, directory.Cron, directory.Timezone, directory.OutsideWindow, directory.Overlap)
+		errs.inFile(since, file)
+	}
+
+	c.validateRoots(&errs)
+
+	return errors.Join(errs...)
+}
+
+// locate returns the file defining a watched directory, none for the main
+// one unless fragments are merged, and its path within the file
+func (c Config) locate(i int) (string, string) {
+	if c.origins == nil {
+		return "", fmt.Sprintf("watchedDirectories[%d]", i)
+	}
+
+	return c.origins[i].file, fmt.Sprintf("watchedDirectories[%d]", c.origins[i].index)
+}
+
+// describe names a watched directory in a problem, along with the file
+// defining it
+func (c Config) describe(i int) string {
+	file, path := c.locate(i)
+
+	if file == "" {
+		return path
+	}
+
+	return path + " of " + file
+}
+
+// validateSchedule checks the settings controlling when the files of a
+// watched directory, or of all of them, may be cleaned
+func validateSchedule(errs *problems, path, cron, timezone, outsideWindow, overlap string) {
+	if cron != "" {
+		_, err := ParseCron(cron)
+		errs.add(field(path, "cron"), err)
+	}
+
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			errs.add(field(path, "timezone"), fmt.Errorf("invalid timezone %q: %w", timezone, err))
+		}
+	}
+
+	switch outsideWindow {
+	case "", "skip", "defer":
+	default:
+		errs.add(field(path, "outsideWindow"), fmt.Errorf("unknown outsideWindow policy %q", outsideWindow))
+	}
+
+	switch overlap {
+	case "", "skip", "queue", "wait":
+	default:
+		errs.add(field(path, "overlap"), fmt.Errorf("unknown overlap policy %q", overlap))
+	}
+}
+
+// validateRemoval checks the settings telling how the files of a watched
+// directory are dated, which of them are removed and how
+func validateRemoval(errs *problems, path string, directory WatchedDirectory) {
+	switch directory.Retention {
+	case "", "age":
+	case "gfs":
+		if !directory.GFS.Keeps() {
+			errs.add(field(path, "gfs"), errors.New("needs a daily, weekly, monthly or yearly count above 0 with the gfs retention"))
+		}
+	default:
+		errs.add(field(path, "retention"), fmt.Errorf("unknown retention %q", directory.Retention))
+	}
+
+	switch directory.AgeSource {
+	case "", "mtime", "atime", "ctime":
+	case "filename":
+		if directory.Filename == nil {
+			errs.add(field(path, "filename"), errors.New("is required with the filename age source"))
+		}
+	default:
+		errs.add(field(path, "ageSource"), fmt.Errorf("unknown age source %q", directory.AgeSource))
+	}
+
+	if directory.Filename != nil {
+		validateFilename(errs, field(path, "filename"), *directory.Filename)
+	}
+
+	used := []string{directory.Action}
+
+	switch directory.Action {
+	case "", "delete", "trash", "archive":
+	default:
+		errs.add(field(path, "action"), fmt.Errorf("unknown action %q", directory.Action))
+	}
+
+	for i, action := range directory.Actions {
+		actionPath := fmt.Sprintf("%s[%d]", field(path, "actions"), i)
+		used = append(used, action.Type)
+
+		switch action.Type {
+		case "compress":
+			switch action.Format {
+			case "", "gzip", "zstd":
+			default:
+				errs.add(field(actionPath, "format"), fmt.Errorf("unknown compression format %q", action.Format))
+			}
+		case "delete", "trash", "archive":
+		case "":
+			errs.add(field(actionPath, "type"), errors.New("is required"))
+		default:
+			errs.add(field(actionPath, "type"), fmt.Errorf("unknown action %q", action.Type))
+		}
+
+		if action.After < 0 {
+			errs.add(field(actionPath, "after"), fmt.Errorf("must not be negative, got %s", action.After))
+		}
+	}
+
+	if slices.Contains(used, "trash") && (directory.Trash == nil || directory.Trash.Path == "") {
+		errs.add(field(path, "trash.path"), errors.New("is required by the trash action"))
+	}
+
+	if slices.Contains(used, "archive") && (directory.Archive == nil || directory.Archive.Path == "") {
+		errs.add(field(path, "archive.path"), errors.New("is required by the archive action"))
+	}
+
+	if directory.Archive != nil {
+		switch directory.Archive.Format {
+		case "", "tar.gz", "zip":
+		default:
+			errs.add(field(path, "archive.format"), fmt.Errorf("unknown archive format %q", directory.Archive.Format))
+		}
+	}
+
+	if directory.Watermark != nil && directory.Watermark.Low.IsZero() {
+		errs.add(field(path, "watermark.low"), errors.New("is required"))
+	}
+
+	if directory.Watermark != nil && directory.Watermark.High.Below(directory.Watermark.Low) {
+		errs.add(field(path, "watermark.high"), errors.New("must not be below low, or cleanups would delete nothing"))
+	}
+}
+
+// validateFilename checks that the date of a file can be read from its name
+func validateFilename(errs *problems, path string, filename FilenameDate) {
+	if filename.Pattern == "" {
+		errs.add(field(path, "pattern"), errors.New("is required"))
+	} else if _, err := regexp.Compile(filename.Pattern); err != nil {
+		errs.add(field(path, "pattern"), fmt.Errorf("invalid pattern %q: %w", filename.Pattern, err))
+	}
+
+	if filename.Layout == "" {
+		errs.add(field(path, "layout"), errors.New("is required"))
+	}
+
+	switch filename.OnError {
+	case "", "error", "skip":
+	default:
+		errs.add(field(path, "onError"), fmt.Errorf("unknown onError policy %q", filename.OnError))
+	}
+}
+
+// validateTrigger checks that the files of a watched directory with the
+// inotify trigger can be expired one by one, by age alone. Without a cron
+// expression, its own or the global one, no pass runs over the directory,
+// so the settings only applied by passes are rejected too.
+func validateTrigger(directory WatchedDirectory, cron string) error {
+	switch directory.Trigger {
+	case "", "cron":
+		return nil
+	case "inotify":
+	default:
+		return fmt.Errorf("unknown trigger %q", directory.Trigger)
+	}
+
+	switch {
+	case directory.Age <= 0:
+		return errors.New("the inotify trigger needs an age")
+	case directory.Retention != "" && directory.Retention != "age":
+		return fmt.Errorf("the inotify trigger can't be used with the %s retention", directory.Retention)
+	case len(directory.Actions) > 0:
+		return errors.New("the inotify trigger can't be used with actions")
+	case directory.KeepLatest > 0:
+		return errors.New("the inotify trigger can't be used with keepLatest")
+	case cron == "" && directory.MaxTotalSize > 0:
+		return errors.New("the inotify trigger can't be used with maxTotalSize without a cron")
+	case cron == "" && directory.RemoveEmptyDirs:
+		return errors.New("the inotify trigger can't be used with removeEmptyDirs without a cron")
+	case cron == "" && directory.Trash != nil && directory.Trash.Age > 0:
+		return errors.New("the inotify trigger can't be used with a trash age without a cron")
+	}
+
+	return nil
+}
+
+// validateAbsolute checks that a path a setting names, if any, is absolute
+// rather than relative to the directory fileman is started from, and tells
+// whether it names one
+func validateAbsolute(errs *problems, path string, value string) bool {
+	if value != "" && !filepath.IsAbs(value) {
+		errs.add(path, fmt.Errorf("must be absolute, got %q", value))
+		return false
+	}
+
+	return value != ""
+}
+
+// validateLock checks that a lock, if any, tells where the leases are kept
+func validateLock(errs *problems, path string, lock *Lock) {
+	if lock == nil {
+		return
+	}
+
+	if lock.Dir == "" {
+		errs.add(field(path, "dir"), errors.New("is required"))
+	}
+
+	validateAbsolute(errs, field(path, "dir"), lock.Dir)
+
+	if lock.TTL < 0 {
+		errs.add(field(path, "ttl"), fmt.Errorf("must not be negative, got %d", lock.TTL))
+	}
+}
+
+// validateRoots checks that watched directories, which are told apart by
+// their path, don't overlap: each directory must be watched once, and never
+// from within another, whose passes could reach its files or remove it once
+// empty
+func (c Config) validateRoots(errs *problems) {
+	for i, directory := range c.WatchedDirectories {
+		if !filepath.IsAbs(directory.Path) {
+			continue
+		}
+
+		file, path := c.locate(i)
+		path = field(path, "path")
+		since := len(*errs)
+
+		for j, other := range c.WatchedDirectories[:i] {
+			if !filepath.IsAbs(other.Path) {
+				continue
+			}
+
+			switch {
+			case filepath.Clean(directory.Path) == filepath.Clean(other.Path):
+				errs.add(path, fmt.Errorf("%s is already watched by %s", directory.Path, c.describe(j)))
+			case isWithin(other.Path, directory.Path):
+				errs.add(path, fmt.Errorf("%s is within %s (%s)", directory.Path, c.describe(j), other.Path))
+			case isWithin(directory.Path, other.Path):
+				errs.add(path, fmt.Errorf("%s holds %s (%s)", directory.Path, c.describe(j), other.Path))
+			}
+		}
+
+		errs.inFile(since, file)
+	}
+}
+
+// validateOutOfReach checks that a directory files are moved to, such as a
+// trash, is out of reach of every watched directory, which would otherwise
+// clean them up again
+func (c Config) validateOutOfReach(errs *problems, path string, dir string) {
+	for j, other := range c.WatchedDirectories {
+		if !filepath.IsAbs(other.Path) {
+			continue
+		}
+
+		if reaches(other, dir) || (other.Recursive && filepath.Clean(dir) == filepath.Clean(other.Path)) {
+			errs.add(path, fmt.Errorf("%s is within %s (%s), which is recursive", dir, c.describe(j), other.Path))
+		}
+	}
+}
+
+// isWithin tells whether a path is below a directory
+func isWithin(dir string, path string) bool {
+	relative, err := filepath.Rel(dir, path)
+
+	return err == nil && relative != "." && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// reaches tells whether the files of a directory within a watched one are
+// cleaned along with it
+func reaches(directory WatchedDirectory, path string) bool {
+	if !directory.Recursive {
+		return false
+	}
+
+	relative, err := filepath.Rel(directory.Path, path)
+
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	depth := strings.Count(relative, string(filepath.Separator)) + 2
+
+	return directory.MaxDepth <= 0 || depth <= directory.MaxDepth
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		errors []string
+	}{
+		{"global", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a"}}}, nil},
+		{"invalid global", Config{Cron: "* * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Cron: "* * * * *"}}}, []string{`cron: invalid cron expression "* * *"`}},
+		{"missing", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Cron: "* * * * *"}, {Path: "/b"}}}, []string{"watchedDirectories[1].cron: is required as no global cron is set"}},
+		{"invalid directory", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Cron: "61 * * * *"}}}, []string{"watchedDirectories[0].cron: invalid cron expression"}},
+		{"never fires", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Cron: "0 0 30 2 *"}}}, []string{`watchedDirectories[0].cron: cron expression "0 0 30 2 *" never fires`}},
+		{"invalid timezone", Config{Cron: "* * * * *", Timezone: "Mars/Olympus", WatchedDirectories: []WatchedDirectory{{Path: "/a"}}}, []string{`timezone: invalid timezone "Mars/Olympus"`}},
+		{"invalid directory timezone", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Timezone: "Berlin"}}}, []string{`watchedDirectories[0].timezone: invalid timezone "Berlin"`}},
+		{"invalid policy", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", OutsideWindow: "later"}}}, []string{`watchedDirectories[0].outsideWindow: unknown outsideWindow policy "later"`}},
+		{"invalid overlap", Config{Cron: "* * * * *", Overlap: "parallel", WatchedDirectories: []WatchedDirectory{{Path: "/a", Overlap: "queue"}}}, []string{`overlap: unknown overlap policy "parallel"`}},
+		{"lock", Config{Cron: "* * * * *", Lock: &Lock{Dir: "/locks"}, WatchedDirectories: []WatchedDirectory{{Path: "/a", Lock: &Lock{Dir: "/a", TTL: 30}}}}, nil},
+		{"lock without dir", Config{Cron: "* * * * *", Lock: &Lock{}, WatchedDirectories: []WatchedDirectory{{Path: "/a", Lock: &Lock{Dir: "/a", TTL: -1}}}}, []string{"lock.dir: is required", "watchedDirectories[0].lock.ttl: must not be negative, got -1"}},
+		{"inotify without cron", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "inotify", Age: Days(1)}}}, nil},
+		{"inotify without age", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "inotify"}}}, []string{"watchedDirectories[0].trigger: the inotify trigger needs an age"}},
+		{"inotify with gfs", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "inotify", Age: Days(1), Retention: "gfs"}}}, []string{"with the gfs retention"}},
+		{"gfs", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Retention: "gfs", GFS: &GFS{Weekly: 4}}}}, nil},
+		{"gfs without counts", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Retention: "gfs"}, {Path: "/b", Retention: "gfs", GFS: &GFS{Daily: 0}}}}, []string{"watchedDirectories[0].gfs: needs a daily, weekly, monthly or yearly count above 0", "watchedDirectories[1].gfs: needs"}},
+		{"inotify with actions", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "inotify", Age: Days(1), Actions: []Action{{Type: "delete"}}}}}, []string{"with actions"}},
+		{"inotify passes without cron", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "inotify", Age: Days(1), MaxTotalSize: 1 << 20}, {Path: "/b", Trigger: "inotify", Age: Days(1), RemoveEmptyDirs: true}, {Path: "/c", Trigger: "inotify", Age: Days(1), Action: "trash", Trash: &Trash{Path: "/trash", Age: Days(7)}}}}, []string{"watchedDirectories[0].trigger: the inotify trigger can't be used with maxTotalSize without a cron", "watchedDirectories[1].trigger: the inotify trigger can't be used with removeEmptyDirs without a cron", "watchedDirectories[2].trigger: the inotify trigger can't be used with a trash age without a cron"}},
+		{"inotify passes with cron", Config{Cron: "0 * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "inotify", Age: Days(1), MaxTotalSize: 1 << 20, RemoveEmptyDirs: true}, {Path: "/b", Trigger: "inotify", Age: Days(1), Action: "trash", Trash: &Trash{Path: "/trash", Age: Days(7)}}}}, nil},
+		{"inotify with keepLatest", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "inotify", Age: Days(1), KeepLatest: 2}}}, []string{"with keepLatest"}},
+		{"unknown trigger", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Trigger: "fanotify"}}}, []string{`watchedDirectories[0].trigger: unknown trigger "fanotify"`}},
+		{"missing path", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Age: Days(1)}}}, []string{"watchedDirectories[0].path: is required"}},
+		{"relative path", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "logs"}}}, []string{`watchedDirectories[0].path: must be absolute, got "logs"`}},
+		{"negative age", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Age: Days(-1), Trash: &Trash{Path: "/trash", Age: Days(-0.5)}}}}, []string{"watchedDirectories[0].age: must not be negative, got -1d", "watchedDirectories[0].trash.age: must not be negative, got -12h"}},
+		{"trash within", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Recursive: true, Trash: &Trash{Path: "/a/.trash"}}, {Path: "/b", Recursive: true, Trash: &Trash{Path: "/b"}}}}, []string{"watchedDirectories[0].trash.path: /a/.trash is within watchedDirectories[0] (/a), which is recursive", "watchedDirectories[1].trash.path: /b is within watchedDirectories[1] (/b)"}},
+		{"trash within another", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Trash: &Trash{Path: "/b/trash"}}, {Path: "/b", Recursive: true}}}, []string{"watchedDirectories[0].trash.path: /b/trash is within watchedDirectories[1] (/b)"}},
+		{"trash out of reach", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Trash: &Trash{Path: "/a/.trash"}}, {Path: "/b", Recursive: true, MaxDepth: 1, Trash: &Trash{Path: "/b/trash"}}}}, nil},
+		{"archive within", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Recursive: true, Archive: &Archive{Path: "/a/archives"}}, {Path: "/b", Archive: &Archive{Path: "/b/archives"}}}}, []string{"watchedDirectories[0].archive.path: /a/archives is within watchedDirectories[0] (/a), which is recursive"}},
+		{"unknown values", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Retention: "lifo", Action: "shred", AgeSource: "birth", Archive: &Archive{Path: "/archives", Format: "rar"}, Actions: []Action{{Type: "compress", Format: "lz4"}, {Type: "move"}, {}}}}}, []string{"watchedDirectories[0].retention: unknown retention \"lifo\"", "watchedDirectories[0].action: unknown action \"shred\"", "watchedDirectories[0].ageSource: unknown age source \"birth\"", "watchedDirectories[0].archive.format: unknown archive format \"rar\"", "watchedDirectories[0].actions[0].format: unknown compression format \"lz4\"", "watchedDirectories[0].actions[1].type: unknown action \"move\"", "watchedDirectories[0].actions[2].type: is required"}},
+		{"trash without path", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Action: "trash"}, {Path: "/b", Actions: []Action{{Type: "trash", After: Days(1)}}, Trash: &Trash{}}}}, []string{"watchedDirectories[0].trash.path: is required by the trash action", "watchedDirectories[1].trash.path: is required by the trash action"}},
+		{"archive without path", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Actions: []Action{{Type: "archive"}}}}}, []string{"watchedDirectories[0].archive.path: is required by the archive action"}},
+		{"negative after", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Actions: []Action{{Type: "compress", After: Days(1)}, {Type: "delete", After: Days(-1)}}}}}, []string{"watchedDirectories[0].actions[1].after: must not be negative"}},
+		{"filename without settings", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", AgeSource: "filename"}}}, []string{"watchedDirectories[0].filename: is required with the filename age source"}},
+		{"invalid filename", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", AgeSource: "filename", Filename: &FilenameDate{Pattern: "(?P<date>[0-9-+", OnError: "ignore"}}}}, []string{"watchedDirectories[0].filename.pattern: invalid pattern", "watchedDirectories[0].filename.layout: is required", "watchedDirectories[0].filename.onError: unknown onError policy \"ignore\""}},
+		{"filename", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", AgeSource: "filename", Filename: &FilenameDate{Pattern: `(?P<date>\d{8})`, Layout: "20060102", OnError: "skip"}}}}, nil},
+		{"watermark without low", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Watermark: &Watermark{High: Threshold{Percent: 20}}}}}, []string{"watchedDirectories[0].watermark.low: is required"}},
+		{"watermark high below low", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Watermark: &Watermark{Low: Threshold{Percent: 20}, High: Threshold{Percent: 10}}}, {Path: "/b", Watermark: &Watermark{Low: Threshold{Bytes: 5 << 30}, High: Threshold{Bytes: 1 << 30}}}}}, []string{"watchedDirectories[0].watermark.high: must not be below low", "watchedDirectories[1].watermark.high: must not be below low"}},
+		{"watermark", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Watermark: &Watermark{Low: Threshold{Percent: 10}, High: Threshold{Percent: 20}}}, {Path: "/b", Watermark: &Watermark{Low: Threshold{Percent: 10}}}, {Path: "/c", Watermark: &Watermark{Low: Threshold{Percent: 10}, High: Threshold{Bytes: 1 << 30}}}}}, nil},
+		{"pipeline", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Retention: "age", AgeSource: "mtime", Actions: []Action{{Type: "compress", Format: "zstd"}, {Type: "archive", After: Days(7)}}, Archive: &Archive{Path: "/archives", Format: "zip"}}}}, nil},
+		{"duplicate", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a"}, {Path: "/a/", Cron: "0 * * * *"}}}, []string{"watchedDirectories[1].path: /a/ is already watched by watchedDirectories[0]"}},
+		{"nested", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", Recursive: true}, {Path: "/a/b/c"}}}, []string{"watchedDirectories[1].path: /a/b/c is within watchedDirectories[0] (/a)"}},
+		{"holding", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a/b"}, {Path: "/a", Recursive: true}}}, []string{"watchedDirectories[1].path: /a holds watchedDirectories[0] (/a/b)"}},
+		{"nested without recursion", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a"}, {Path: "/a/b"}, {Path: "/c", Recursive: true, MaxDepth: 2}, {Path: "/c/d/e"}}}, []string{"watchedDirectories[1].path: /a/b is within watchedDirectories[0] (/a)", "watchedDirectories[3].path: /c/d/e is within watchedDirectories[2] (/c)"}},
+		{"siblings", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a"}, {Path: "/ab"}, {Path: "/c/a"}, {Path: "/"}}}, []string{"watchedDirectories[3].path: / holds watchedDirectories[0] (/a)"}},
+		{"relative paths", Config{Cron: "* * * * *", State: "state.json", Lock: &Lock{Dir: "locks"}, WatchedDirectories: []WatchedDirectory{{Path: "/a", Action: "trash", Trash: &Trash{Path: "trash"}, Archive: &Archive{Path: "./archives"}, Lock: &Lock{Dir: "../locks"}}}}, []string{"state: must be absolute, got \"state.json\"", "lock.dir: must be absolute, got \"locks\"", "watchedDirectories[0].trash.path: must be absolute, got \"trash\"", "watchedDirectories[0].archive.path: must be absolute, got \"./archives\"", "watchedDirectories[0].lock.dir: must be absolute, got \"../locks\""}},
+		{"negative limits", Config{Cron: "* * * * *", WatchedDirectories: []WatchedDirectory{{Path: "/a", KeepLatest: -5, MaxDepth: -2, MaxTotalSize: -1}}}, []string{"watchedDirectories[0].keepLatest: must not be negative, got -5", "watchedDirectories[0].maxDepth: must not be negative, got -2", "watchedDirectories[0].maxTotalSize: must not be negative, got -1"}},
+		{"several", Config{WatchedDirectories: []WatchedDirectory{{Path: "/a"}, {Path: "/b", Cron: "foo"}}}, []string{"watchedDirectories[0].cron: is required", "watchedDirectories[1].cron: invalid"}},
+	}
+
+	for _, test := range tests {
+		err := test.config.Validate()
+
+		if test.errors == nil {
+			assert.NoError(t, err, test.name)
+			continue
+		}
+
+		if assert.Error(t, err, test.name) {
+			for _, message := range test.errors {
+				assert.Contains(t, err.Error(), message, test.name)
+			}
+		}
+	}
+}
+
+func TestValidateReportsFieldErrors(t *testing.T) {
+	err := Config{WatchedDirectories: []WatchedDirectory{{Path: "a", Age: Days(-1)}}}.Validate()
+
+	fieldError := &FieldError{}
+	assert.True(t, errors.As(err, &fieldError))
+	assert.Equal(t, "watchedDirectories[0].path", fieldError.Path)
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 3)
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	_, err := New("testdata/config_invalid.json").Load()
+
+	if assert.Error(t, err) {
+		assert.Equal(t, "crone: unknown field\n"+
+			"watchedDirectories[0]: expected an object, got a string\n"+
+			"watchedDirectories[1]: expected an object, got a string", err.Error())
+	}
+}
+
+func TestDecodeStrictly(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		errors   []string
+	}{
+		{"valid", `{"cron": "* * * * *", "gracePeriod": 10, "watchedDirectories": [{"path": "/a", "age": 1.5, "maxTotalSize": "1GB", "lock": null}]}`, nil},
+		{"fields regardless of case", `{"CRON": "* * * * *", "WatchedDirectories": [{"Path": "/a"}]}`, nil},
+		{"unknown nested field", `{"watchedDirectories": [{"path": "/a", "ages": 1, "trash": {"path": "/t", "purge": true}}]}`, []string{"watchedDirectories[0].ages: unknown field", "watchedDirectories[0].trash.purge: unknown field"}},
+		{"wrong kinds", `{"cron": 5, "dryRun": "yes", "gracePeriod": 1.5, "watchedDirectories": {"path": "/a"}}`, []string{"cron: expected a string, got a number", "dryRun: expected a boolean, got a string", "gracePeriod: expected an integer, got 1.5", "watchedDirectories: expected an array, got an object"}},
+		{"custom types", `{"windows": [{"days": ["Mon"], "start": "25:00"}, {"from": "01:00"}], "watchedDirectories": [{"path": "/a", "maxTotalSize": "1 parsec"}]}`, []string{"windows[0]: invalid time of day", `windows[1]: json: unknown field "from"`, "watchedDirectories[0].maxTotalSize: invalid size unit"}},
+		{"syntax", `{"cron": }`, []string{"invalid character"}},
+		{"trailing content", `{"cron": ""} {}`, []string{"after top-level value"}},
+	}
+
+	for _, test := range tests {
+		err := decodeWith(DecodeJSON, test.document, &Config{})
+
+		if test.errors == nil {
+			assert.NoError(t, err, test.name)
+			continue
+		}
+
+		if assert.Error(t, err, test.name) {
+			for _, message := range test.errors {
+				assert.Contains(t, err.Error(), message, test.name)
+			}
+		}
+	}
+
+	err := decodeWith(DecodeYAML, "watchedDirectories:\n  - path: /a\n    recursve: true\n", &Config{})
+	assert.EqualError(t, err, "watchedDirectories[0].recursve: unknown field")
+
+	err = decodeWith(DecodeTOML, "[[watchedDirectories]]\npath = \"/a\"\nrecursive = \"yes\"\n", &Config{})
+	assert.EqualError(t, err, "watchedDirectories[0].recursive: expected a boolean, got a string")
+}
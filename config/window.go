@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a maintenance window files may be removed in, written e.g. as
+// {"days": ["Mon-Fri"], "start": "01:00", "end": "05:00"}. Without days it
+// opens every day. A window whose end isn't after its start spans midnight,
+// its days being the ones it opens on, and "00:00" to "00:00" is a whole day.
+type Window struct {
+	Days  []time.Weekday
+	Start time.Duration
+	End   time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWeekday parses a day name, either abbreviated or in full
+func parseWeekday(value string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+
+	if len(name) >= 3 {
+		if day, ok := weekdays[name[:3]]; ok && strings.HasPrefix(strings.ToLower(day.String()), name) {
+			return day, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid day %q", value)
+}
+
+// parseDays parses day names and ranges such as "Mon-Fri" or "Sat-Sun"
+func parseDays(values []string) ([]time.Weekday, error) {
+	days := make([]time.Weekday, 0, len(values))
+
+	for _, value := range values {
+		first, last, isRange := strings.Cut(value, "-")
+
+		from, err := parseWeekday(first)
+
+		if err != nil {
+			return nil, err
+		}
+
+		to := from
+
+		if isRange {
+			if to, err = parseWeekday(last); err != nil {
+				return nil, err
+			}
+		}
+
+		for day := from; ; day = (day + 1) % 7 {
+			days = append(days, day)
+
+			if day == to {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// parseTimeOfDay parses a "15:04" time into the duration since midnight,
+// "24:00" being accepted as the end of the day
+func parseTimeOfDay(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	parsed, err := time.Parse("15:04", value)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+func (w *Window) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Days  []string
+		Start string
+		End   string
+	}
+
+	if err := unmarshalStrict(data, &raw); err != nil {
+		return err
+	}
+
+	days, err := parseDays(raw.Days)
+
+	if err != nil {
+		return err
+	}
+
+	start, err := parseTimeOfDay(raw.Start)
+
+	if err != nil {
+		return err
+	}
+
+	end, err := parseTimeOfDay(raw.End)
+
+	if err != nil {
+		return err
+	}
+
+	*w = Window{Days: days, Start: start % (24 * time.Hour), End: end}
+
+	return nil
+}
+
+// opensOn tells whether the window opens on the given day
+func (w Window) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sinceMidnight returns the time elapsed since the start of the day of t
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+}
+
+// Contains tells whether t, in the location of the watched directory,
+// falls within the window
+func (w Window) Contains(t time.Time) bool {
+	offset := sinceMidnight(t)
+
+	if w.Start < w.End {
+		return w.opensOn(t.Weekday()) && offset >= w.Start && offset < w.End
+	}
+
+	if offset >= w.Start {
+		return w.opensOn(t.Weekday())
+	}
+
+	return offset < w.End && w.opensOn((t.Weekday()+6)%7)
+}
+
+// NextOpening returns the first time after t the window opens at
+func (w Window) NextOpening(t time.Time) time.Time {
+	hour, minute := int(w.Start/time.Hour), int(w.Start%time.Hour/time.Minute)
+
+	for i := 0; i <= 7; i++ {
+		opening := time.Date(t.Year(), t.Month(), t.Day()+i, hour, minute, 0, 0, t.Location())
+
+		if opening.After(t) && w.opensOn(opening.Weekday()) {
+			return opening
+		}
+	}
+
+	return time.Time{}
+}
+
+// InWindow tells whether t falls within any of the windows, which is
+// always the case when there are none
+func InWindow(windows []Window, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+
+	for _, window := range windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextWindow returns the first time after t any of the windows opens at
+func NextWindow(windows []Window, t time.Time) time.Time {
+	next := time.Time{}
+
+	for _, window := range windows {
+		opening := window.NextOpening(t)
+
+		if !opening.IsZero() && (next.IsZero() || opening.Before(next)) {
+			next = opening
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalWindow(t *testing.T) {
+	var window Window
+
+	err := json.Unmarshal([]byte(`{"days": ["Mon-Wed", "friday"], "start": "01:00", "end": "05:30"}`), &window)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Window{
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Friday},
+		Start: time.Hour,
+		End:   5*time.Hour + 30*time.Minute,
+	}, window)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"days": ["Sat-Mon"], "start": "22:00", "end": "24:00"}`), &window))
+	assert.Equal(t, []time.Weekday{time.Saturday, time.Sunday, time.Monday}, window.Days)
+	assert.Equal(t, 24*time.Hour, window.End)
+
+	for _, data := range []string{
+		`{"days": ["Mo"], "start": "01:00", "end": "05:00"}`,
+		`{"days": ["Mon-Fry"], "start": "01:00", "end": "05:00"}`,
+		`{"start": "1am", "end": "05:00"}`,
+		`{"start": "01:00", "end": "25:00"}`,
+		`{"start": "01:00"}`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(data), &window), data)
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	weekdays := Window{
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start: time.Hour,
+		End:   5 * time.Hour,
+	}
+	overnight := Window{Days: []time.Weekday{time.Friday}, Start: 22 * time.Hour, End: 2 * time.Hour}
+	daily := Window{}
+
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	assert.True(t, weekdays.Contains(at(1, 1, 0)))
+	assert.True(t, weekdays.Contains(at(5, 4, 59)))
+	assert.False(t, weekdays.Contains(at(1, 5, 0)))
+	assert.False(t, weekdays.Contains(at(1, 0, 59)))
+	assert.False(t, weekdays.Contains(at(6, 2, 0)))
+
+	assert.True(t, overnight.Contains(at(5, 23, 0)))
+	assert.True(t, overnight.Contains(at(6, 1, 0)))
+	assert.False(t, overnight.Contains(at(6, 23, 0)))
+	assert.False(t, overnight.Contains(at(5, 1, 0)))
+
+	assert.True(t, daily.Contains(at(3, 0, 0)))
+	assert.True(t, daily.Contains(at(7, 23, 59)))
+
+	assert.True(t, InWindow(nil, at(1, 12, 0)))
+	assert.False(t, InWindow([]Window{weekdays, overnight}, at(1, 12, 0)))
+	assert.True(t, InWindow([]Window{weekdays, overnight}, at(6, 1, 0)))
+}
+
+func TestWindowNextOpening(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	weekdays := Window{
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start: time.Hour,
+		End:   5 * time.Hour,
+	}
+	saturday := Window{Days: []time.Weekday{time.Saturday}, Start: 3 * time.Hour, End: 4 * time.Hour}
+
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 1, day, hour, 0, 0, 0, berlin)
+	}
+
+	assert.Equal(t, at(1, 1), weekdays.NextOpening(at(1, 0)))
+	assert.Equal(t, at(2, 1), weekdays.NextOpening(at(1, 1)))
+	assert.Equal(t, at(8, 1), weekdays.NextOpening(at(5, 12)))
+	assert.Equal(t, at(6, 3), NextWindow([]Window{weekdays, saturday}, at(5, 12)))
+	assert.True(t, NextWindow(nil, at(5, 12)).IsZero())
+}
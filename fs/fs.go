@@ -1,11 +1,34 @@
 package fs
 
-import "os"
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
 
 type FileSystem interface {
 	ReadDir(path string) ([]os.DirEntry, error)
 	DeleteFile(path string) error
 	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte) error
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	Chtimes(path string, atime time.Time, mtime time.Time) error
+	Chmod(path string, mode os.FileMode) error
+	Stat(path string) (os.FileInfo, error)
+	MkdirAll(path string) error
+	Rename(oldPath string, newPath string) error
+	Statfs(path string) (DiskUsage, error)
+	Watch(ctx context.Context, path string, recursive bool) (<-chan Event, error)
+}
+
+// DiskUsage describes the space of the filesystem holding a path, in bytes
+type DiskUsage struct {
+	Total uint64
+	Free  uint64
 }
 
 type FS struct{}
@@ -20,8 +43,109 @@ func (f FS) ReadFile(path string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
+// WriteFile writes data to a given file, creating it if needed
+func (f FS) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Open opens a given file for reading
+func (f FS) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// Create creates a given file for writing. It fails if the file
+// already exists rather than truncating it.
+func (f FS) Create(path string) (io.WriteCloser, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+}
+
+// Chtimes changes the access and modification times of a given file
+func (f FS) Chtimes(path string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(path, atime, mtime)
+}
+
+// Chmod changes the permissions of a given file
+func (f FS) Chmod(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+// Stat returns the details of a given file
+func (f FS) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// MkdirAll creates a given directory along with its missing parents
+func (f FS) MkdirAll(path string) error {
+	return os.MkdirAll(path, 0o755)
+}
+
 // DeleteFile deletes a given file from the filesystem.
 // If something went wrong, an error of type *PathError is returned.
 func (f FS) DeleteFile(path string) error {
 	return os.Remove(path)
 }
+
+// Rename moves a given file to a new path. When both paths are on
+// different devices, the file is copied, keeping its mode and
+// modification time, and then deleted.
+func (f FS) Rename(oldPath string, newPath string) error {
+	err := os.Rename(oldPath, newPath)
+
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyFile(oldPath, newPath); err != nil {
+		return err
+	}
+
+	return os.Remove(oldPath)
+}
+
+// Statfs returns the total and free space of the filesystem holding
+// a given path. Free space is the one available to unprivileged users.
+func (f FS) Statfs(path string) (DiskUsage, error) {
+	return statfs(path)
+}
+
+func copyFile(oldPath string, newPath string) error {
+	info, err := os.Stat(oldPath)
+
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(oldPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	target, err := os.OpenFile(newPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(target, source)
+
+	if err == nil {
+		err = target.Sync()
+	}
+
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chtimes(newPath, info.ModTime(), info.ModTime())
+	}
+
+	if err != nil {
+		os.Remove(newPath)
+	}
+
+	return err
+}
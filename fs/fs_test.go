@@ -0,0 +1,67 @@
+package fs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatfsReportsUsage(t *testing.T) {
+	usage, err := FS{}.Statfs(t.TempDir())
+
+	assert.Nil(t, err)
+	assert.Positive(t, usage.Total)
+	assert.LessOrEqual(t, usage.Free, usage.Total)
+}
+
+func TestStatfsMissingPath(t *testing.T) {
+	_, err := FS{}.Statfs("does/not/exist")
+
+	assert.Error(t, err)
+}
+
+func TestRenameMovesFile(t *testing.T) {
+	root := t.TempDir()
+	oldPath := filepath.Join(root, "old.txt")
+	newPath := filepath.Join(root, "new.txt")
+
+	assert.Nil(t, os.WriteFile(oldPath, []byte("foo"), 0o600))
+	assert.Nil(t, FS{}.Rename(oldPath, newPath))
+
+	content, err := os.ReadFile(newPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", string(content))
+	assert.NoFileExists(t, oldPath)
+}
+
+func TestCopyFileKeepsModeAndModificationTime(t *testing.T) {
+	root := t.TempDir()
+	oldPath := filepath.Join(root, "old.txt")
+	newPath := filepath.Join(root, "new.txt")
+	modTime := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+
+	assert.Nil(t, os.WriteFile(oldPath, []byte("foo"), 0o600))
+	assert.Nil(t, os.Chtimes(oldPath, modTime, modTime))
+	assert.Nil(t, copyFile(oldPath, newPath))
+
+	info, err := os.Stat(newPath)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.True(t, info.ModTime().Equal(modTime))
+	assert.FileExists(t, oldPath)
+}
+
+func TestCopyFileDoesNotOverwrite(t *testing.T) {
+	root := t.TempDir()
+	oldPath := filepath.Join(root, "old.txt")
+	newPath := filepath.Join(root, "new.txt")
+
+	assert.Nil(t, os.WriteFile(oldPath, []byte("foo"), 0o600))
+	assert.Nil(t, os.WriteFile(newPath, []byte("bar"), 0o600))
+	assert.Error(t, copyFile(oldPath, newPath))
+
+	content, _ := os.ReadFile(newPath)
+	assert.Equal(t, "bar", string(content))
+}
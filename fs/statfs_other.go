@@ -0,0 +1,12 @@
+//go:build !(linux || darwin)
+
+package fs
+
+import (
+	"errors"
+	"os"
+)
+
+func statfs(path string) (DiskUsage, error) {
+	return DiskUsage{}, &os.PathError{Op: "statfs", Path: path, Err: errors.ErrUnsupported}
+}
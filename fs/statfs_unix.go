@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+func statfs(path string) (DiskUsage, error) {
+	stat := syscall.Statfs_t{}
+
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return DiskUsage{}, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	return DiskUsage{
+		Total: stat.Blocks * uint64(stat.Bsize),
+		Free:  stat.Bavail * uint64(stat.Bsize),
+	}, nil
+}
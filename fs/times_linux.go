@@ -0,0 +1,29 @@
+package fs
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// AccessTime returns the last access time (atime) of a file
+func AccessTime(info os.FileInfo) (time.Time, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(stat.Atim.Unix()), true
+}
+
+// ChangeTime returns the last status change time (ctime) of a file
+func ChangeTime(info os.FileInfo) (time.Time, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(stat.Ctim.Unix()), true
+}
@@ -0,0 +1,20 @@
+//go:build !(linux || darwin)
+
+package fs
+
+import (
+	"os"
+	"time"
+)
+
+// AccessTime returns the last access time (atime) of a file, which
+// isn't available on this platform
+func AccessTime(info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// ChangeTime returns the last status change time (ctime) of a file,
+// which isn't available on this platform
+func ChangeTime(info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package fs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccessAndChangeTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	accessed := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+
+	assert.Nil(t, os.WriteFile(path, []byte("foo"), 0o644))
+	assert.Nil(t, os.Chtimes(path, accessed, time.Now()))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+
+	atime, ok := AccessTime(info)
+	assert.True(t, ok)
+	assert.True(t, atime.Equal(accessed))
+
+	ctime, ok := ChangeTime(info)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now(), ctime, time.Minute)
+}
@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Event is a change in a watched directory: a file created, written or
+// moved in, or, when Removed, deleted or moved out. Err reports a failure
+// of the watch itself.
+type Event struct {
+	Path    string
+	Removed bool
+	Err     error
+}
+
+// Watch reports the changes in a given directory, and in its
+// subdirectories when recursive, until the context is done. Files found in
+// the subdirectories appearing meanwhile are reported as created, as they
+// may have been written before the subdirectory was watched.
+func (f FS) Watch(ctx context.Context, path string, recursive bool) (<-chan Event, error) {
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(path); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	if recursive {
+		if err := watchTree(watcher, path, nil); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+		defer watcher.Close()
+
+		send := func(event Event) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-watcher.Errors:
+				if !ok || !send(Event{Err: err}) {
+					return
+				}
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+					if !send(Event{Path: event.Name, Removed: true}) {
+						return
+					}
+
+					continue
+				}
+
+				info, err := os.Lstat(event.Name)
+
+				if err != nil || !info.IsDir() {
+					if !send(Event{Path: event.Name}) {
+						return
+					}
+
+					continue
+				}
+
+				if !recursive {
+					continue
+				}
+
+				found := make([]string, 0)
+				if err := watchTree(watcher, event.Name, &found); err != nil && !send(Event{Err: err}) {
+					return
+				}
+
+				for _, file := range found {
+					if !send(Event{Path: file}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// watchTree adds the subdirectories of a given directory to the watcher,
+// collecting the files met along the way when found isn't nil. Symbolic
+// links to directories aren't followed.
+func watchTree(watcher *fsnotify.Watcher, root string, found *[]string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			if found != nil {
+				*found = append(*found, path)
+			}
+
+			return nil
+		}
+
+		if path == root && found == nil {
+			return nil
+		}
+
+		return watcher.Add(path)
+	})
+}
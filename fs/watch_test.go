@@ -0,0 +1,81 @@
+package fs
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// awaitEvent waits for an event about a given path, skipping the others
+func awaitEvent(t *testing.T, events <-chan Event, path string, removed bool) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case event := <-events:
+			if event.Path == path && event.Removed == removed {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event for %s", path)
+		}
+	}
+}
+
+func TestWatchReportsChanges(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := FS{}.Watch(ctx, root, false)
+	assert.Nil(t, err)
+
+	path := filepath.Join(root, "new.log")
+	assert.Nil(t, os.WriteFile(path, []byte("foo"), 0o600))
+	awaitEvent(t, events, path, false)
+
+	assert.Nil(t, os.Remove(path))
+	awaitEvent(t, events, path, true)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchRecursiveReportsFilesOfNewDirectories(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "existing"), 0o755))
+
+	events, err := FS{}.Watch(ctx, root, true)
+	assert.Nil(t, err)
+
+	path := filepath.Join(root, "existing", "a.log")
+	assert.Nil(t, os.WriteFile(path, []byte("foo"), 0o600))
+	awaitEvent(t, events, path, false)
+
+	staging := filepath.Join(t.TempDir(), "batch")
+	assert.Nil(t, os.MkdirAll(staging, 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(staging, "b.log"), []byte("foo"), 0o600))
+	assert.Nil(t, os.Rename(staging, filepath.Join(root, "batch")))
+
+	path = filepath.Join(root, "batch", "b.log")
+	awaitEvent(t, events, path, false)
+
+	path = filepath.Join(root, "batch", "c.log")
+	assert.Nil(t, os.WriteFile(path, []byte("foo"), 0o600))
+	awaitEvent(t, events, path, false)
+}
+
+func TestWatchMissingPath(t *testing.T) {
+	_, err := FS{}.Watch(context.Background(), "does/not/exist", false)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,338 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fileman/config"
+	"fileman/fs"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxArchiveAttempts bounds the names tried for the archive of a pass
+// when the previous ones are taken
+const maxArchiveAttempts = 100
+
+// archivePending stores the files collected by the archive action into a
+// single archive named after the watched directory and the time of the
+// pass, suffixed with a counter when an archive of the same name already
+// exists, such as one of another directory of the same name. The archive is
+// read back to check every file made it whole, and only then are the
+// originals deleted.
+func (f FileHandler) archivePending(fs fs.FileSystem, directory config.WatchedDirectory, result *Result) {
+	pending := result.pending
+	result.pending = nil
+
+	if len(pending) == 0 {
+		return
+	}
+
+	target, err := f.archivePath(directory, 0)
+
+	if err == nil && !directory.IsDryRun() {
+		err = fs.MkdirAll(directory.Archive.Path)
+	}
+
+	for attempt := 1; err == nil && !directory.IsDryRun(); attempt++ {
+		err = writeArchive(fs, target, directory, pending)
+
+		if !errors.Is(err, os.ErrExist) || attempt == maxArchiveAttempts {
+			break
+		}
+
+		target, err = f.archivePath(directory, attempt)
+	}
+
+	if err != nil {
+		for _, file := range pending {
+			file.deleted = false
+		}
+
+		result.Errors = append(result.Errors, err)
+		return
+	}
+
+	result.Archive = target
+
+	for _, file := range pending {
+		if !directory.IsDryRun() {
+			if err := fs.DeleteFile(file.path); err != nil {
+				file.deleted = false
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+		}
+
+		result.Archived = append(result.Archived, file.path)
+	}
+}
+
+// archivePath returns the path of the archive of the current pass, suffixed
+// with the attempt when the previous names are taken
+func (f FileHandler) archivePath(directory config.WatchedDirectory, attempt int) (string, error) {
+	if directory.Archive == nil || directory.Archive.Path == "" {
+		return "", errors.New("archive action of " + directory.Path + " needs an archive path")
+	}
+
+	format := directory.Archive.Format
+	if format == "" {
+		format = "tar.gz"
+	}
+
+	if format != "tar.gz" && format != "zip" {
+		return "", fmt.Errorf("unknown archive format %q", format)
+	}
+
+	name := fmt.Sprintf("%s-%s",
+		filepath.Base(directory.Path),
+		time.Unix(f.clock.Unix(), 0).UTC().Format("20060102T150405"),
+	)
+
+	if attempt > 0 {
+		name = fmt.Sprintf("%s-%d", name, attempt)
+	}
+
+	name += "." + format
+
+	return filepath.Join(directory.Archive.Path, name), nil
+}
+
+// writeArchive writes the files into the target archive, which must not
+// exist yet, and checks it can be read back. The archive is deleted when
+// either fails.
+func writeArchive(fs fs.FileSystem, target string, directory config.WatchedDirectory, files []*File) error {
+	output, err := fs.Create(target)
+
+	if err != nil {
+		return err
+	}
+
+	expected := make(map[string]int64)
+	zipped := filepath.Ext(target) == ".zip"
+
+	if zipped {
+		err = writeZip(fs, output, directory.Path, files, expected)
+	} else {
+		err = writeTarGz(fs, output, directory.Path, files, expected)
+	}
+
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = verifyArchive(fs, target, zipped, expected)
+	}
+
+	if err != nil {
+		fs.DeleteFile(target)
+		return fmt.Errorf("archiving to %s: %w", target, err)
+	}
+
+	return nil
+}
+
+func writeTarGz(fs fs.FileSystem, output io.Writer, root string, files []*File, written map[string]int64) error {
+	compressor := gzip.NewWriter(output)
+	archive := tar.NewWriter(compressor)
+
+	for _, file := range files {
+		name, info, source, err := openEntry(fs, root, file)
+
+		if err != nil {
+			return err
+		}
+
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     info.Size(),
+			Mode:     int64(info.Mode().Perm()),
+			ModTime:  info.ModTime(),
+		}
+
+		if err = archive.WriteHeader(header); err == nil {
+			written[name], err = io.CopyN(archive, source, info.Size())
+		}
+
+		source.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return compressor.Close()
+}
+
+func writeZip(fs fs.FileSystem, output io.Writer, root string, files []*File, written map[string]int64) error {
+	archive := zip.NewWriter(output)
+
+	for _, file := range files {
+		name, info, source, err := openEntry(fs, root, file)
+
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+
+		if err == nil {
+			header.Name = name
+			header.Method = zip.Deflate
+
+			var entry io.Writer
+			if entry, err = archive.CreateHeader(header); err == nil {
+				written[name], err = io.CopyN(entry, source, info.Size())
+			}
+		}
+
+		source.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// openEntry opens a file to archive, returning its name in the archive,
+// its path relative to the watched directory, along with its current details.
+// Details are read again as the file may have changed since the listing.
+func openEntry(fs fs.FileSystem, root string, file *File) (string, os.FileInfo, io.ReadCloser, error) {
+	relative, err := filepath.Rel(root, file.path)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	info, err := fs.Stat(file.path)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	source, err := fs.Open(file.path)
+
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return filepath.ToSlash(relative), info, source, nil
+}
+
+// verifyArchive reads the whole archive back, which checks its checksums,
+// and compares its entries with the expected names and sizes
+func verifyArchive(fs fs.FileSystem, path string, zipped bool, expected map[string]int64) error {
+	input, err := fs.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer input.Close()
+
+	found := make(map[string]int64)
+
+	if zipped {
+		err = readZip(fs, path, input, found)
+	} else {
+		err = readTarGz(input, found)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !maps.Equal(expected, found) {
+		return errors.New("archive content doesn't match the archived files")
+	}
+
+	return nil
+}
+
+func readTarGz(input io.Reader, found map[string]int64) error {
+	decompressor, err := gzip.NewReader(input)
+
+	if err != nil {
+		return err
+	}
+
+	archive := tar.NewReader(decompressor)
+
+	for {
+		header, err := archive.Next()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if found[header.Name], err = io.Copy(io.Discard, archive); err != nil {
+			return err
+		}
+	}
+
+	// Reading up to the end of the gzip stream checks its checksum
+	_, err = io.Copy(io.Discard, decompressor)
+
+	return err
+}
+
+func readZip(fs fs.FileSystem, path string, input io.Reader, found map[string]int64) error {
+	info, err := fs.Stat(path)
+
+	if err != nil {
+		return err
+	}
+
+	readerAt, ok := input.(io.ReaderAt)
+
+	if !ok {
+		content, err := io.ReadAll(input)
+
+		if err != nil {
+			return err
+		}
+
+		readerAt = bytes.NewReader(content)
+	}
+
+	archive, err := zip.NewReader(readerAt, info.Size())
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range archive.File {
+		content, err := entry.Open()
+
+		if err != nil {
+			return err
+		}
+
+		found[entry.Name], err = io.Copy(io.Discard, content)
+		content.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
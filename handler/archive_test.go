@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fileman/clock"
+	"fileman/config"
+	filesystem "fileman/fs"
+	"fileman/mocks"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanArchivesFilesToTarGz(t *testing.T) {
+	root := t.TempDir()
+	watched := filepath.Join(root, "artifacts")
+	archives := filepath.Join(root, "archives")
+
+	writeAgedFile(t, filepath.Join(watched, "build", "old.bin"), 10*24*time.Hour)
+	writeAgedFile(t, filepath.Join(watched, "old.txt"), 10*24*time.Hour)
+	writeAgedFile(t, filepath.Join(watched, "new.txt"), time.Hour)
+
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path:      watched,
+		Age:       config.Days(7),
+		Recursive: true,
+		Action:    "archive",
+		Archive:   &config.Archive{Path: archives},
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, 0, len(result.Deleted))
+	assert.ElementsMatch(t, []string{filepath.Join(watched, "build", "old.bin"), filepath.Join(watched, "old.txt")}, result.Archived)
+	assert.Regexp(t, `artifacts-\d{8}T\d{6}\.tar\.gz$`, result.Archive)
+	assert.NoFileExists(t, filepath.Join(watched, "build", "old.bin"))
+	assert.NoFileExists(t, filepath.Join(watched, "old.txt"))
+	assert.FileExists(t, filepath.Join(watched, "new.txt"))
+
+	archive, err := os.Open(result.Archive)
+	assert.Nil(t, err)
+	defer archive.Close()
+
+	decompressor, err := gzip.NewReader(archive)
+	assert.Nil(t, err)
+
+	reader := tar.NewReader(decompressor)
+	contents := map[string]string{}
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		assert.Nil(t, err)
+		content, _ := io.ReadAll(reader)
+		contents[header.Name] = string(content)
+	}
+
+	assert.Equal(t, map[string]string{
+		"build/old.bin": filepath.Join(watched, "build", "old.bin"),
+		"old.txt":       filepath.Join(watched, "old.txt"),
+	}, contents)
+}
+
+func TestCleanArchivesFilesToZip(t *testing.T) {
+	root := t.TempDir()
+	watched := filepath.Join(root, "artifacts")
+	archives := filepath.Join(root, "archives")
+
+	writeAgedFile(t, filepath.Join(watched, "old.txt"), 10*24*time.Hour)
+
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path:    watched,
+		Age:     config.Days(7),
+		Action:  "archive",
+		Archive: &config.Archive{Path: archives, Format: "zip"},
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{filepath.Join(watched, "old.txt")}, result.Archived)
+	assert.NoFileExists(t, filepath.Join(watched, "old.txt"))
+
+	archive, err := zip.OpenReader(result.Archive)
+	assert.Nil(t, err)
+	defer archive.Close()
+
+	assert.Equal(t, 1, len(archive.File))
+	assert.Equal(t, "old.txt", archive.File[0].Name)
+}
+
+func TestCleanKeepsFilesWhenArchivingFails(t *testing.T) {
+	root := t.TempDir()
+	watched := filepath.Join(root, "artifacts")
+	archives := filepath.Join(root, "archives")
+
+	writeAgedFile(t, filepath.Join(watched, "old.txt"), 10*24*time.Hour)
+	writeAgedFile(t, archives, time.Hour)
+
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path:    watched,
+		Age:     config.Days(7),
+		Action:  "archive",
+		Archive: &config.Archive{Path: archives},
+	})
+
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, 0, len(result.Archived))
+	assert.Equal(t, "", result.Archive)
+	assert.FileExists(t, filepath.Join(watched, "old.txt"))
+}
+
+func TestCleanDryRunWritesNoArchive(t *testing.T) {
+	root := t.TempDir()
+	watched := filepath.Join(root, "artifacts")
+	archives := filepath.Join(root, "archives")
+
+	writeAgedFile(t, filepath.Join(watched, "old.txt"), 10*24*time.Hour)
+
+	dryRun := true
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path:    watched,
+		Age:     config.Days(7),
+		Action:  "archive",
+		Archive: &config.Archive{Path: archives},
+		DryRun:  &dryRun,
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{filepath.Join(watched, "old.txt")}, result.Archived)
+	assert.Regexp(t, `artifacts-\d{8}T\d{6}\.tar\.gz$`, result.Archive)
+	assert.FileExists(t, filepath.Join(watched, "old.txt"))
+	assert.NoFileExists(t, result.Archive)
+	assert.NoDirExists(t, archives)
+}
+
+func TestCleanArchivesUnderAFreeName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().Unix().Return(int64(1755907200)).AnyTimes()
+	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(days(10)).AnyTimes()
+
+	root := t.TempDir()
+	archives := filepath.Join(root, "archives")
+	fileHandler := FileHandler{clock: mockClock}
+
+	for i, team := range []string{"api", "web", "web"} {
+		watched := filepath.Join(root, team, "logs")
+		writeAgedFile(t, filepath.Join(watched, "old.log"), 10*24*time.Hour)
+
+		result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+			Path:    watched,
+			Age:     config.Days(7),
+			Action:  "archive",
+			Archive: &config.Archive{Path: archives},
+		})
+
+		expected := filepath.Join(archives, "logs-20250823T000000.tar.gz")
+		if i > 0 {
+			expected = filepath.Join(archives, fmt.Sprintf("logs-20250823T000000-%d.tar.gz", i))
+		}
+
+		assert.Equal(t, 0, len(result.Errors))
+		assert.Equal(t, expected, result.Archive)
+		assert.Equal(t, []string{filepath.Join(watched, "old.log")}, result.Archived)
+	}
+
+	entries, err := os.ReadDir(archives)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"compress/gzip"
+	"fileman/config"
+	"fileman/fs"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionSuffixes lists the extensions the compress action adds to
+// the files it compresses
+var compressionSuffixes = []string{".gz", ".zst"}
+
+// compressedExtensions lists the extensions of files that are already
+// compressed and are left alone by the compress action
+var compressedExtensions = []string{".gz", ".tgz", ".zst", ".zip", ".bz2", ".xz", ".lz4", ".7z"}
+
+// isCompressed tells whether a file is already compressed, judging by
+// its extension
+func isCompressed(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+
+	for _, compressed := range compressedExtensions {
+		if extension == compressed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uncompressedPath returns the path of a file compressed by the compress
+// action as it was before, and false for any other file
+func uncompressedPath(path string) (string, bool) {
+	extension := filepath.Ext(path)
+
+	for _, suffix := range compressionSuffixes {
+		if strings.EqualFold(extension, suffix) && len(path) > len(extension) {
+			return strings.TrimSuffix(path, extension), true
+		}
+	}
+
+	return "", false
+}
+
+// compress compresses a file with the given format, recording the outcome
+// in the result. In dry run mode, the file is only recorded as compressed.
+func (f FileHandler) compress(fs fs.FileSystem, file *File, format string, directory config.WatchedDirectory, result *Result) bool {
+	path := file.path
+
+	if !directory.IsDryRun() {
+		if err := f.compressFile(fs, file, format); err != nil {
+			result.Errors = append(result.Errors, err)
+			return false
+		}
+	}
+
+	result.Compressed = append(result.Compressed, path)
+
+	return true
+}
+
+// compressFile compresses a file next to itself with the given format,
+// gzip or zstd, and deletes it. The compressed file is written to a hidden
+// temporary file, renamed into place once complete so that an interrupted
+// pass never leaves a partial one behind, and keeps the original mode and
+// modification time so that its age is unchanged. A file already there
+// under its name, left by a pass interrupted before deleting the original,
+// is replaced. The file is updated to describe the compressed one.
+func (f FileHandler) compressFile(fs fs.FileSystem, file *File, format string) error {
+	extension := ""
+
+	switch format {
+	case "", "gzip":
+		extension = ".gz"
+	case "zstd":
+		extension = ".zst"
+	default:
+		return fmt.Errorf("unknown compression format %q", format)
+	}
+
+	info := file.info
+	if info == nil {
+		var err error
+		if info, err = fs.Stat(file.path); err != nil {
+			return err
+		}
+	}
+
+	target := file.path + extension
+	temporary := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".tmp")
+
+	fs.DeleteFile(temporary)
+	size, err := writeCompressed(fs, file.path, temporary, format)
+
+	if err != nil {
+		return err
+	}
+
+	if err := fs.Chmod(temporary, info.Mode().Perm()); err != nil {
+		fs.DeleteFile(temporary)
+		return err
+	}
+
+	if err := fs.Chtimes(temporary, info.ModTime(), info.ModTime()); err != nil {
+		fs.DeleteFile(temporary)
+		return err
+	}
+
+	if err := fs.Rename(temporary, target); err != nil {
+		fs.DeleteFile(temporary)
+		return err
+	}
+
+	if err := fs.DeleteFile(file.path); err != nil {
+		fs.DeleteFile(target)
+		return err
+	}
+
+	file.path = target
+	file.name = filepath.Base(target)
+	file.size = size
+
+	return nil
+}
+
+// writeCompressed writes the compressed content of a file to the target,
+// which must not exist yet, returning the size written. The target is
+// deleted when the compression fails.
+func writeCompressed(fs fs.FileSystem, path string, target string, format string) (int64, error) {
+	source, err := fs.Open(path)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer source.Close()
+
+	output, err := fs.Create(target)
+
+	if err != nil {
+		return 0, err
+	}
+
+	counter := &countingWriter{writer: output}
+	var compressor io.WriteCloser
+
+	if format == "zstd" {
+		compressor, err = zstd.NewWriter(counter)
+	} else {
+		compressor = gzip.NewWriter(counter)
+	}
+
+	if err == nil {
+		_, err = io.Copy(compressor, source)
+
+		if closeErr := compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		fs.DeleteFile(target)
+	}
+
+	return counter.written, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	written, err := c.writer.Write(data)
+	c.written += int64(written)
+
+	return written, err
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"compress/gzip"
+	"fileman/clock"
+	"fileman/config"
+	filesystem "fileman/fs"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanRunsActionsPipeline(t *testing.T) {
+	root := t.TempDir()
+
+	writeAgedFile(t, filepath.Join(root, "recent.log"), time.Hour)
+	writeAgedFile(t, filepath.Join(root, "yesterday.log"), 2*24*time.Hour)
+	writeAgedFile(t, filepath.Join(root, "rotated.log.gz"), 2*24*time.Hour)
+	writeAgedFile(t, filepath.Join(root, "expired.log"), 40*24*time.Hour)
+	writeAgedFile(t, filepath.Join(root, "expired.log.gz"), 40*24*time.Hour)
+
+	before, err := os.Stat(filepath.Join(root, "yesterday.log"))
+	assert.Nil(t, err)
+
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path: root,
+		Actions: []config.Action{
+			{Type: "compress", After: config.Days(1)},
+			{Type: "delete", After: config.Days(30)},
+		},
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{filepath.Join(root, "yesterday.log")}, result.Compressed)
+	assert.ElementsMatch(t, []string{filepath.Join(root, "expired.log"), filepath.Join(root, "expired.log.gz")}, result.Deleted)
+	assert.FileExists(t, filepath.Join(root, "recent.log"))
+	assert.FileExists(t, filepath.Join(root, "rotated.log.gz"))
+	assert.NoFileExists(t, filepath.Join(root, "rotated.log.gz.gz"))
+	assert.NoFileExists(t, filepath.Join(root, "yesterday.log"))
+
+	after, err := os.Stat(filepath.Join(root, "yesterday.log.gz"))
+	assert.Nil(t, err)
+	assert.True(t, after.ModTime().Equal(before.ModTime()))
+
+	compressed, err := os.Open(filepath.Join(root, "yesterday.log.gz"))
+	assert.Nil(t, err)
+	defer compressed.Close()
+
+	reader, err := gzip.NewReader(compressed)
+	assert.Nil(t, err)
+
+	content, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(root, "yesterday.log"), string(content))
+}
+
+func TestCompressFileWithZstd(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app.log")
+	writeAgedFile(t, path, 2*24*time.Hour)
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+
+	file := &File{name: "app.log", path: path, info: info}
+	err = FileHandler{}.compressFile(filesystem.FS{}, file, "zstd")
+
+	assert.Nil(t, err)
+	assert.Equal(t, path+".zst", file.path)
+	assert.Equal(t, "app.log.zst", file.name)
+	assert.NoFileExists(t, path)
+
+	compressed, err := os.Open(path + ".zst")
+	assert.Nil(t, err)
+	defer compressed.Close()
+
+	reader, err := zstd.NewReader(compressed)
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, path, string(content))
+
+	stat, err := os.Stat(path + ".zst")
+	assert.Nil(t, err)
+	assert.Equal(t, stat.Size(), file.size)
+}
+
+func TestCompressFileReplacesStaleTarget(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app.log")
+	writeAgedFile(t, path, 2*24*time.Hour)
+	assert.Nil(t, os.Chmod(path, 0o600))
+	assert.Nil(t, os.WriteFile(path+".gz", []byte("partial"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, ".app.log.gz.tmp"), []byte("partial"), 0o644))
+
+	err := FileHandler{}.compressFile(filesystem.FS{}, &File{name: "app.log", path: path}, "gzip")
+
+	assert.Nil(t, err)
+	assert.NoFileExists(t, path)
+	assert.NoFileExists(t, filepath.Join(root, ".app.log.gz.tmp"))
+
+	info, err := os.Stat(path + ".gz")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	compressed, err := os.Open(path + ".gz")
+	assert.Nil(t, err)
+	defer compressed.Close()
+
+	reader, err := gzip.NewReader(compressed)
+	assert.Nil(t, err)
+
+	content, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, path, string(content))
+}
+
+func TestCleanDryRunCompressesOnce(t *testing.T) {
+	root := t.TempDir()
+	writeAgedFile(t, filepath.Join(root, "app.log"), 3*24*time.Hour)
+
+	dryRun := true
+	result := New(clock.RealClock{}).Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path:   root,
+		DryRun: &dryRun,
+		Actions: []config.Action{
+			{Type: "compress", After: config.Days(1)},
+			{Type: "compress", After: config.Days(2), Format: "zstd"},
+		},
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{filepath.Join(root, "app.log")}, result.Compressed)
+	assert.FileExists(t, filepath.Join(root, "app.log"))
+}
+
+func TestCleanDeletesCompressedFilesMatchingIncludes(t *testing.T) {
+	root := t.TempDir()
+	writeAgedFile(t, filepath.Join(root, "app.log"), 2*24*time.Hour)
+	writeAgedFile(t, filepath.Join(root, "old.log.gz"), 40*24*time.Hour)
+	writeAgedFile(t, filepath.Join(root, "old.txt.gz"), 40*24*time.Hour)
+
+	directory := config.WatchedDirectory{
+		Path:    root,
+		Include: []string{"*.log"},
+		Actions: []config.Action{
+			{Type: "compress", After: config.Days(1)},
+			{Type: "delete", After: config.Days(30)},
+		},
+	}
+	result := New(clock.RealClock{}).Clean(filesystem.FS{}, directory)
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{filepath.Join(root, "app.log")}, result.Compressed)
+	assert.Equal(t, []string{filepath.Join(root, "old.log.gz")}, result.Deleted)
+	assert.Equal(t, []string{filepath.Join(root, "old.txt.gz")}, result.Skipped)
+	assert.FileExists(t, filepath.Join(root, "app.log.gz"))
+}
+
+func TestIsCompressed(t *testing.T) {
+	assert.True(t, isCompressed("app.log.gz"))
+	assert.True(t, isCompressed("APP.LOG.ZST"))
+	assert.False(t, isCompressed("app.log"))
+	assert.False(t, isCompressed("gz"))
+}
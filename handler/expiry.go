@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"container/heap"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// expiry is a file of a watched directory due for removal at a deadline
+type expiry struct {
+	path     string
+	deadline time.Time
+	index    int
+}
+
+// The removal of a file that failed is retried after minRetryDelay, twice
+// as long after each failure in a row, up to maxRetryDelay
+const (
+	minRetryDelay = 10 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// expiryQueue is a min-heap of the deadlines of the watched files, each
+// file being scheduled at most once, along with the number of failures in
+// a row of the removal of the files retried
+type expiryQueue struct {
+	items    []*expiry
+	byPath   map[string]*expiry
+	failures map[string]int
+}
+
+func newExpiryQueue() *expiryQueue {
+	return &expiryQueue{byPath: make(map[string]*expiry), failures: make(map[string]int)}
+}
+
+func (q *expiryQueue) Len() int {
+	return len(q.items)
+}
+
+func (q *expiryQueue) Less(i, j int) bool {
+	return q.items[i].deadline.Before(q.items[j].deadline)
+}
+
+func (q *expiryQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+func (q *expiryQueue) Push(x any) {
+	item := x.(*expiry)
+	item.index = len(q.items)
+	q.items = append(q.items, item)
+	q.byPath[item.path] = item
+}
+
+func (q *expiryQueue) Pop() any {
+	last := len(q.items) - 1
+	item := q.items[last]
+	q.items[last] = nil
+	q.items = q.items[:last]
+	delete(q.byPath, item.path)
+
+	return item
+}
+
+// schedule sets the deadline of a file, replacing the previous one
+func (q *expiryQueue) schedule(path string, deadline time.Time) {
+	if item, ok := q.byPath[path]; ok {
+		item.deadline = deadline
+		heap.Fix(q, item.index)
+		return
+	}
+
+	heap.Push(q, &expiry{path: path, deadline: deadline})
+}
+
+// retry schedules a file again once its removal failed, backing off
+func (q *expiryQueue) retry(path string, now time.Time) {
+	delay := maxRetryDelay
+	if failures := q.failures[path]; failures < 16 {
+		delay = min(minRetryDelay<<failures, maxRetryDelay)
+	}
+
+	q.failures[path]++
+	q.schedule(path, now.Add(delay))
+}
+
+// forget drops the failures of a file done with
+func (q *expiryQueue) forget(path string) {
+	delete(q.failures, path)
+}
+
+// cancel unschedules a file, or every file below it when it is a directory
+func (q *expiryQueue) cancel(path string) {
+	prefix := path + string(filepath.Separator)
+
+	for _, item := range q.byPath {
+		if item.path == path || strings.HasPrefix(item.path, prefix) {
+			heap.Remove(q, item.index)
+		}
+	}
+
+	for failed := range q.failures {
+		if failed == path || strings.HasPrefix(failed, prefix) {
+			delete(q.failures, failed)
+		}
+	}
+}
+
+// next returns the earliest scheduled file, or nil when there is none
+func (q *expiryQueue) next() *expiry {
+	if len(q.items) == 0 {
+		return nil
+	}
+
+	return q.items[0]
+}
+
+// due pops the files whose deadline isn't after now, earliest first
+func (q *expiryQueue) due(now time.Time) []string {
+	paths := make([]string, 0)
+
+	for len(q.items) > 0 && !q.items[0].deadline.After(now) {
+		paths = append(paths, heap.Pop(q).(*expiry).path)
+	}
+
+	return paths
+}
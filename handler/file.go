@@ -1,21 +1,31 @@
 package handler
 
+import (
+	"os"
+	"time"
+)
+
 type File struct {
 	createdAt int64
-	age       float64
+	age       time.Duration
 	name      string
 	path      string
+	size      int64
 	isDir     bool
+	deleted   bool
+	info      os.FileInfo
 	error     error
 }
 
-func NewFile(createdAt int64, age float64, name string, path string, isDir bool, error error) *File {
+func NewFile(createdAt int64, age time.Duration, name string, path string, size int64, isDir bool, info os.FileInfo, error error) *File {
 	return &File{
 		createdAt: createdAt,
 		age:       age,
 		name:      name,
 		path:      path,
+		size:      size,
 		isDir:     isDir,
+		info:      info,
 		error:     error,
 	}
 }
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"fileman/config"
+	"fileman/fs"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RotateBackups applies the grandfather-father-son rotation of the watched
+// directory: it keeps the newest file of each of the last daily days, weekly
+// weeks, monthly months and yearly years holding a file, along with the
+// keepLatest newest files, and deletes the others. Periods are counted over
+// the files themselves, so backups are never all rotated out when they stop
+// being produced.
+func (f FileHandler) RotateBackups(fs fs.FileSystem, directory config.WatchedDirectory) Result {
+	result := NewResult(directory.IsDryRun())
+	files, candidates := f.collectFiles(fs, directory, result)
+	f.rotate(fs, candidates, directory, result)
+
+	f.finish(fs, files, directory, result)
+
+	return *result
+}
+
+// rotate deletes the candidates that aren't kept by the rotation of the
+// directory, returning the candidates left in place. Periods follow the
+// timestamps given by the directory age source. A rotation without counts
+// is reported as an error and deletes nothing, rather than every file.
+func (f FileHandler) rotate(fs fs.FileSystem, candidates []*File, directory config.WatchedDirectory, result *Result) []*File {
+	if !directory.GFS.Keeps() {
+		result.Errors = append(result.Errors, fmt.Errorf("gfs retention of %s keeps no backups, none rotated out", directory.Path))
+		return candidates
+	}
+
+	gfs := *directory.GFS
+
+	dates := make(map[*File]time.Time)
+	for _, file := range candidates {
+		dates[file] = time.Unix(file.createdAt, 0)
+	}
+
+	kept := newest(candidates, directory.KeepLatest)
+	for file := range rotationKept(dates, gfs) {
+		kept[file] = true
+	}
+
+	for _, file := range candidates {
+		if !kept[file] {
+			f.removeFile(fs, file, directory.Action, directory, result)
+		}
+	}
+
+	return remaining(candidates)
+}
+
+// rotationKept returns the newest file of each period kept by the rotation
+func rotationKept(dates map[*File]time.Time, gfs config.GFS) map[*File]bool {
+	files := make([]*File, 0, len(dates))
+	for file := range dates {
+		files = append(files, file)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if dates[files[i]].Equal(dates[files[j]]) {
+			return files[i].path < files[j].path
+		}
+
+		return dates[files[i]].After(dates[files[j]])
+	})
+
+	periods := []struct {
+		count int
+		key   func(time.Time) string
+	}{
+		{gfs.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{gfs.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{gfs.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{gfs.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+
+	kept := make(map[*File]bool)
+
+	for _, period := range periods {
+		last := ""
+		left := period.count
+
+		for _, file := range files {
+			if left <= 0 {
+				break
+			}
+
+			key := period.key(dates[file])
+
+			if key != last {
+				kept[file] = true
+				last = key
+				left--
+			}
+		}
+	}
+
+	return kept
+}
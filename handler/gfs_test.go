@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"fileman/config"
+	"fileman/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func TestRotationKeepsNewestFileOfEachPeriod(t *testing.T) {
+	dates := map[*File]time.Time{}
+	files := map[string]*File{}
+
+	for _, day := range []string{
+		"2025-08-22T03:00", "2025-08-22T01:00", "2025-08-21T03:00", "2025-08-20T03:00",
+		"2025-08-10T03:00", "2025-07-31T03:00", "2025-07-01T03:00", "2024-12-31T03:00",
+	} {
+		date, _ := time.Parse("2006-01-02T15:04", day)
+		file := &File{path: day}
+		files[day] = file
+		dates[file] = date
+	}
+
+	kept := rotationKept(dates, config.GFS{Daily: 2, Weekly: 2, Monthly: 3, Yearly: 2})
+
+	keptPaths := make([]string, 0)
+	for day, file := range files {
+		if kept[file] {
+			keptPaths = append(keptPaths, day)
+		}
+	}
+
+	assert.ElementsMatch(t, []string{
+		"2025-08-22T03:00",
+		"2025-08-21T03:00",
+		"2025-08-10T03:00",
+		"2025-07-31T03:00",
+		"2024-12-31T03:00",
+	}, keptPaths)
+}
+
+func TestRotateBackupsWithFilenameDates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	modTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(0)).Times(4)
+	mockClock.EXPECT().CalculateAge(gomock.Not(int64(1755907200))).Return(days(1)).Times(3)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("backups").Return([]fs.DirEntry{
+		mockEntry(ctrl, "backup_20250822T0300.tar.gz", modTime, false),
+		mockEntry(ctrl, "backup_20250821T0300.tar.gz", modTime, false),
+		mockEntry(ctrl, "backup_20250820T0300.tar.gz", modTime, false),
+		mockEntry(ctrl, "notes.txt", modTime, false),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("backups/backup_20250820T0300.tar.gz").Return(nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.RotateBackups(mockFS, config.WatchedDirectory{
+		Path:      "backups",
+		Retention: "gfs",
+		GFS:       &config.GFS{Daily: 2},
+		AgeSource: "filename",
+		Filename: &config.FilenameDate{
+			Pattern: `backup_(?P<date>\d{8}T\d{4})`,
+			Layout:  "20060102T1504",
+		},
+	})
+	assert.Equal(t, []string{"backups/backup_20250820T0300.tar.gz"}, result.Deleted)
+	assert.Equal(t, 1, len(result.Errors))
+}
+
+func TestRotateBackupsWithoutCounts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	modTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(days(30)).AnyTimes()
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	for _, gfs := range []*config.GFS{nil, {}} {
+		mockFS.EXPECT().ReadDir("backups").Return([]fs.DirEntry{
+			mockEntry(ctrl, "backup_20250822T0300.tar.gz", modTime, false),
+			mockEntry(ctrl, "backup_20250821T0300.tar.gz", modTime, false),
+		}, nil).Times(1)
+
+		result := fileHandler.RotateBackups(mockFS, config.WatchedDirectory{Path: "backups", Retention: "gfs", GFS: gfs})
+
+		assert.Empty(t, result.Deleted)
+		assert.Equal(t, 1, len(result.Errors))
+	}
+}
@@ -2,14 +2,27 @@ package handler
 
 import (
 	"container/list"
+	"context"
 	"fileman/clock"
+	"fileman/config"
 	"fileman/fs"
+	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"time"
 )
 
 type IFileHandler interface {
 	ListFiles(fs fs.FileSystem, path string) (list.List, error)
-	DeleteOldFiles(fs fs.FileSystem, path string, threshold float64) ([]string, []error)
+	WalkFiles(fs fs.FileSystem, path string, maxDepth int) (list.List, []error)
+	DeleteOldFiles(fs fs.FileSystem, directory config.WatchedDirectory) Result
+	EnforceQuota(fs fs.FileSystem, directory config.WatchedDirectory) Result
+	FreeSpace(fs fs.FileSystem, directory config.WatchedDirectory) Result
+	RotateBackups(fs fs.FileSystem, directory config.WatchedDirectory) Result
+	Clean(fs fs.FileSystem, directory config.WatchedDirectory) Result
+	Watch(ctx context.Context, fs fs.FileSystem, directory config.WatchedDirectory, guard func(task func()) bool, report func(Result))
 }
 
 type FileHandler struct {
@@ -45,7 +58,9 @@ func (f FileHandler) ListFiles(fs fs.FileSystem, path string) (list.List, error)
 			file.createdAt = info.ModTime().Unix()
 			file.age = f.clock.CalculateAge(info.ModTime().Unix())
 			file.path = filepath.Join(path, entry.Name())
+			file.size = info.Size()
 			file.isDir = info.IsDir()
+			file.info = info
 		}
 
 		files.PushBack(file)
@@ -54,36 +69,509 @@ func (f FileHandler) ListFiles(fs fs.FileSystem, path string) (list.List, error)
 	return files, nil
 }
 
-// DeleteOldFiles deletes files older than the given threshold (in days)
-// from the given path. It returns a list of errors encountered during the process.
-func (f FileHandler) DeleteOldFiles(fs fs.FileSystem, path string, threshold float64) ([]string, []error) {
-	files, err := f.ListFiles(fs, path)
-	deletedFiles := make([]string, 0)
+// WalkFiles lists the files in a given directory and in its subdirectories,
+// descending at most maxDepth levels (1 lists the given directory only,
+// 0 means no limit). Each directory is listed before its content. An error
+// reading the given directory is returned alone, errors reading its
+// subdirectories are collected and the walk carries on.
+func (f FileHandler) WalkFiles(fs fs.FileSystem, path string, maxDepth int) (list.List, []error) {
+	files := list.List{}
 	errors := make([]error, 0)
 
+	root, err := f.ListFiles(fs, path)
+
 	if err != nil {
 		errors = append(errors, err)
-		return deletedFiles, errors
+		return list.List{}, errors
+	}
+
+	f.walk(fs, root, 1, maxDepth, &files, &errors)
+
+	return files, errors
+}
+
+func (f FileHandler) walk(fs fs.FileSystem, entries list.List, depth int, maxDepth int, files *list.List, errors *[]error) {
+	for e := entries.Front(); e != nil; e = e.Next() {
+		file := e.Value.(*File)
+		files.PushBack(file)
+
+		if file.error != nil || !file.isDir || (maxDepth > 0 && depth >= maxDepth) {
+			continue
+		}
+
+		children, err := f.ListFiles(fs, file.path)
+
+		if err != nil {
+			*errors = append(*errors, err)
+			continue
+		}
+
+		f.walk(fs, children, depth+1, maxDepth, files, errors)
+	}
+}
+
+// Clean applies the retention policies of the watched directory in a
+// single pass: files are first deleted by age, or rotated out with the
+// "gfs" retention, then the oldest remaining ones until the directory fits
+// in its maxTotalSize quota, and then until its filesystem is back above
+// the free space watermark. A directory configured with a quota or a
+// watermark but no age isn't pruned by age. Files are deleted or trashed
+// depending on the directory action, or go through its actions pipeline
+// when it has one, and the trash is purged at the end.
+func (f FileHandler) Clean(fs fs.FileSystem, directory config.WatchedDirectory) Result {
+	result := NewResult(directory.IsDryRun())
+	files, candidates := f.collectFiles(fs, directory, result)
+
+	switch directory.Retention {
+	case "gfs":
+		candidates = f.rotate(fs, candidates, directory, result)
+	case "", "age":
+		if len(directory.Actions) > 0 {
+			candidates = f.runActions(fs, candidates, directory, result)
+		} else if directory.Age > 0 || (directory.MaxTotalSize == 0 && directory.Watermark == nil) {
+			candidates = f.deleteOld(fs, candidates, directory, result)
+		}
+	default:
+		result.Errors = append(result.Errors, fmt.Errorf("unknown retention %q", directory.Retention))
+		return *result
+	}
+
+	if directory.MaxTotalSize > 0 {
+		candidates = f.enforceQuota(fs, files, candidates, directory, result)
+	}
+
+	if directory.Watermark != nil {
+		f.freeSpace(fs, candidates, directory, result)
+	}
+
+	f.finish(fs, files, directory, result)
+
+	f.purgeTrash(fs, directory, result)
+
+	return *result
+}
+
+// DeleteOldFiles deletes files older than the directory age threshold
+// (in days) from the given directory, and from its subdirectories when
+// the directory is recursive. The keepLatest newest files are kept whatever
+// their age. Files filtered out by the directory include and exclude
+// patterns are reported as skipped. When the directory has removeEmptyDirs
+// set, the directories left empty are removed afterwards. It returns the
+// deleted paths together with the errors encountered during the process.
+func (f FileHandler) DeleteOldFiles(fs fs.FileSystem, directory config.WatchedDirectory) Result {
+	result := NewResult(directory.IsDryRun())
+	files, candidates := f.collectFiles(fs, directory, result)
+	f.deleteOld(fs, candidates, directory, result)
+
+	f.finish(fs, files, directory, result)
+
+	return *result
+}
+
+// EnforceQuota deletes the oldest files of the watched directory until the
+// size of all its files drops to its maxTotalSize. The keepLatest newest
+// files and those filtered out by the include and exclude patterns are
+// never deleted, though they count towards the total size.
+func (f FileHandler) EnforceQuota(fs fs.FileSystem, directory config.WatchedDirectory) Result {
+	result := NewResult(directory.IsDryRun())
+	files, candidates := f.collectFiles(fs, directory, result)
+	f.enforceQuota(fs, files, candidates, directory, result)
+
+	f.finish(fs, files, directory, result)
+
+	return *result
+}
+
+// FreeSpace checks the free space of the filesystem holding the watched
+// directory and, when it is below the directory low watermark, deletes its
+// oldest files until the free space reaches the high watermark. The space
+// freed is estimated from the size of the deleted files.
+func (f FileHandler) FreeSpace(fs fs.FileSystem, directory config.WatchedDirectory) Result {
+	result := NewResult(directory.IsDryRun())
+
+	if directory.Watermark == nil {
+		return *result
+	}
+
+	usage, err := fs.Statfs(directory.Path)
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return *result
+	}
+
+	if usage.Free >= directory.Watermark.Low.Of(usage.Total) {
+		return *result
+	}
+
+	files, candidates := f.collectFiles(fs, directory, result)
+	f.deleteUntilFree(fs, candidates, directory, usage, result)
+
+	f.finish(fs, files, directory, result)
+
+	return *result
+}
+
+// deleteOld deletes the candidates older than the age threshold that aren't
+// among the keepLatest newest, returning the candidates left in place.
+func (f FileHandler) deleteOld(fs fs.FileSystem, candidates []*File, directory config.WatchedDirectory, result *Result) []*File {
+	kept := newest(candidates, directory.KeepLatest)
+	remaining := make([]*File, 0)
+
+	for _, file := range candidates {
+		if kept[file] || file.age <= time.Duration(directory.Age) || !f.removeFile(fs, file, directory.Action, directory, result) {
+			remaining = append(remaining, file)
+		}
+	}
+
+	return remaining
+}
+
+// runActions runs the actions pipeline of the directory over the candidates
+// older than the after threshold (in days) of a stage, the keepLatest newest
+// aside. A file due for a delete, archive or trash stage goes through the
+// first of them straight away, without being compressed first. Otherwise,
+// it is compressed by the first of its compress stages, unless it is
+// already compressed. It returns the candidates left in place.
+func (f FileHandler) runActions(fs fs.FileSystem, candidates []*File, directory config.WatchedDirectory, result *Result) []*File {
+	kept := newest(candidates, directory.KeepLatest)
+
+	for _, file := range candidates {
+		if kept[file] {
+			continue
+		}
+
+		due := make([]config.Action, 0)
+		for _, action := range directory.Actions {
+			if file.age > time.Duration(action.After) {
+				due = append(due, action)
+			}
+		}
+
+		removal := slices.IndexFunc(due, func(action config.Action) bool {
+			return action.Type != "compress"
+		})
+
+		if removal != -1 {
+			f.removeFile(fs, file, due[removal].Type, directory, result)
+			continue
+		}
+
+		if len(due) > 0 && !isCompressed(file.name) {
+			f.compress(fs, file, due[0].Format, directory, result)
+		}
+	}
+
+	return remaining(candidates)
+}
+
+// enforceQuota deletes the oldest candidates, sparing the keepLatest newest,
+// until the files left in the listing fit in the directory maxTotalSize.
+// It returns the candidates left in place.
+func (f FileHandler) enforceQuota(fs fs.FileSystem, files list.List, candidates []*File, directory config.WatchedDirectory, result *Result) []*File {
+	total := totalSize(files)
+	kept := newest(candidates, directory.KeepLatest)
+
+	for _, file := range oldestFirst(candidates) {
+		if total <= int64(directory.MaxTotalSize) {
+			break
+		}
+
+		if !kept[file] && f.removeFile(fs, file, directory.Action, directory, result) {
+			total -= file.size
+		}
+	}
+
+	return remaining(candidates)
+}
+
+// freeSpace deletes the oldest candidates, sparing the keepLatest newest,
+// when the filesystem holding the directory is below its low watermark.
+func (f FileHandler) freeSpace(fs fs.FileSystem, candidates []*File, directory config.WatchedDirectory, result *Result) {
+	usage, err := fs.Statfs(directory.Path)
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return
+	}
+
+	if usage.Free < directory.Watermark.Low.Of(usage.Total) {
+		f.deleteUntilFree(fs, candidates, directory, usage, result)
+	}
+}
+
+// deleteUntilFree deletes the oldest candidates, sparing the keepLatest
+// newest, until the free space reaches the directory high watermark.
+func (f FileHandler) deleteUntilFree(fs fs.FileSystem, candidates []*File, directory config.WatchedDirectory, usage fs.DiskUsage, result *Result) {
+	high := directory.Watermark.High
+	if high.IsZero() {
+		high = directory.Watermark.Low
+	}
+
+	target := high.Of(usage.Total)
+	free := usage.Free
+	kept := newest(candidates, directory.KeepLatest)
+
+	for _, file := range oldestFirst(candidates) {
+		if free >= target {
+			return
+		}
+
+		if !kept[file] && f.removeFile(fs, file, directory.Action, directory, result) {
+			free += uint64(file.size)
+		}
+	}
+}
+
+// removeFile deletes a file, or moves it to the quarantine directory with
+// the trash action, recording the outcome in the result. With the archive
+// action, the file is only collected, to be archived and deleted along with
+// the others at the end of the pass. In dry run mode, the file is only
+// recorded as removed.
+func (f FileHandler) removeFile(fs fs.FileSystem, file *File, action string, directory config.WatchedDirectory, result *Result) bool {
+	var err error
+
+	switch action {
+	case "", "delete":
+		if !directory.IsDryRun() {
+			err = fs.DeleteFile(file.path)
+		}
+	case "trash":
+		if !directory.IsDryRun() {
+			err = f.trashFile(fs, file, directory)
+		}
+	case "archive":
+		result.pending = append(result.pending, file)
+		file.deleted = true
+		return true
+	default:
+		err = fmt.Errorf("unknown action %q", action)
+	}
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return false
+	}
+
+	file.deleted = true
+
+	if action == "trash" {
+		result.Trashed = append(result.Trashed, file.path)
+	} else {
+		result.Deleted = append(result.Deleted, file.path)
+	}
+
+	return true
+}
+
+// collectFiles walks the watched directory and returns every listed entry
+// along with the files eligible for deletion, in listing order, their age
+// taken from the directory age source. Entries that couldn't be read or
+// dated are added to the result errors, and files filtered out by the
+// include and exclude patterns to the skipped ones.
+func (f FileHandler) collectFiles(fs fs.FileSystem, directory config.WatchedDirectory, result *Result) (list.List, []*File) {
+	maxDepth := 1
+	if directory.Recursive {
+		maxDepth = directory.MaxDepth
+	}
+
+	files, errors := f.WalkFiles(fs, directory.Path, maxDepth)
+	result.Errors = append(result.Errors, errors...)
+	candidates := make([]*File, 0)
+	source, err := newAgeSource(directory)
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return files, candidates
 	}
 
 	for e := files.Front(); e != nil; e = e.Next() {
 		file := e.Value.(*File)
 
 		if file.error != nil {
-			errors = append(errors, file.error)
+			result.Errors = append(result.Errors, file.error)
 			continue
 		}
 
-		if !file.isDir && file.age > threshold {
-			err := fs.DeleteFile(file.path)
+		if file.isDir {
+			continue
+		}
 
-			if err != nil {
-				errors = append(errors, err)
-			} else {
-				deletedFiles = append(deletedFiles, file.path)
-			}
+		selected, err := isSelected(directory, file.path)
+
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		if !selected {
+			result.Skipped = append(result.Skipped, file.path)
+			continue
+		}
+
+		dated, err := f.date(file, source, directory)
+
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		if !dated {
+			result.Skipped = append(result.Skipped, file.path)
+			continue
 		}
+
+		candidates = append(candidates, file)
+	}
+
+	return files, candidates
+}
+
+// date sets the age of a listed file from the age source of the directory.
+// It returns false for a file to skip, whose name holds no date while the
+// directory filename onError is "skip".
+func (f FileHandler) date(file *File, source *ageSource, directory config.WatchedDirectory) (bool, error) {
+	if source.kind == "mtime" {
+		return true, nil
+	}
+
+	timestamp, err := source.time(file)
+
+	if err != nil && directory.Filename != nil && directory.Filename.OnError == "skip" {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
 	}
 
-	return deletedFiles, errors
+	file.createdAt = timestamp.Unix()
+	file.age = f.clock.CalculateAge(timestamp.Unix())
+
+	return true, nil
+}
+
+// newest returns the count most recently modified files
+func newest(files []*File, count int) map[*File]bool {
+	kept := make(map[*File]bool)
+
+	if count <= 0 {
+		return kept
+	}
+
+	sorted := oldestFirst(files)
+
+	for _, file := range sorted[max(len(sorted)-count, 0):] {
+		kept[file] = true
+	}
+
+	return kept
+}
+
+// oldestFirst returns a copy of the files sorted by modification time
+func oldestFirst(files []*File) []*File {
+	sorted := make([]*File, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].createdAt < sorted[j].createdAt
+	})
+
+	return sorted
+}
+
+// remaining returns the files that weren't deleted
+func remaining(files []*File) []*File {
+	left := make([]*File, 0)
+
+	for _, file := range files {
+		if !file.deleted {
+			left = append(left, file)
+		}
+	}
+
+	return left
+}
+
+// totalSize sums the size of the listed files that weren't deleted
+func totalSize(files list.List) int64 {
+	total := int64(0)
+
+	for e := files.Front(); e != nil; e = e.Next() {
+		file := e.Value.(*File)
+
+		if file.error == nil && !file.isDir && !file.deleted {
+			total += file.size
+		}
+	}
+
+	return total
+}
+
+// finish completes a pass over the watched directory: the files collected
+// by the archive action are archived and deleted, then the directories
+// left empty are removed when the directory has removeEmptyDirs set.
+func (f FileHandler) finish(fs fs.FileSystem, files list.List, directory config.WatchedDirectory, result *Result) {
+	f.archivePending(fs, directory, result)
+
+	if directory.RemoveEmptyDirs {
+		f.removeEmptyDirs(fs, files, directory, result)
+	}
+}
+
+// removeEmptyDirs removes the listed directories older than the directory
+// age threshold that have no entries left and aren't excluded. Directories
+// are visited bottom-up so a parent emptied by the removal of its children
+// is removed in the same pass. Ages come from the listing, taken before
+// any file was deleted, as deleting an entry refreshes the modification
+// time of its parent. Entries deleted in the pass don't count, so that dry
+// runs report the directories a real run would remove.
+func (f FileHandler) removeEmptyDirs(fs fs.FileSystem, files list.List, directory config.WatchedDirectory, result *Result) {
+	removed := make(map[string]bool)
+	for e := files.Front(); e != nil; e = e.Next() {
+		if file := e.Value.(*File); file.deleted {
+			removed[file.path] = true
+		}
+	}
+
+	for e := files.Back(); e != nil; e = e.Prev() {
+		file := e.Value.(*File)
+
+		if file.error != nil || !file.isDir || file.age <= time.Duration(directory.Age) {
+			continue
+		}
+
+		excluded, err := isExcluded(directory, file.path)
+
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		if excluded {
+			continue
+		}
+
+		entries, err := fs.ReadDir(file.path)
+
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		if slices.ContainsFunc(entries, func(entry os.DirEntry) bool {
+			return !removed[filepath.Join(file.path, entry.Name())]
+		}) {
+			continue
+		}
+
+		if !directory.IsDryRun() {
+			if err := fs.DeleteFile(file.path); err != nil {
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+		}
+
+		removed[file.path] = true
+		result.RemovedDirs = append(result.RemovedDirs, file.path)
+	}
 }
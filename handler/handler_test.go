@@ -2,6 +2,8 @@ package handler
 
 import (
 	"errors"
+	"fileman/config"
+	filesystem "fileman/fs"
 	"fileman/mocks"
 	"github.com/stretchr/testify/assert"
 	gomock "go.uber.org/mock/gomock"
@@ -15,12 +17,13 @@ func TestListFiles(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockClock := mocks.NewMockClock(ctrl)
-	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(3.0)
+	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(days(3))
 
 	fileCreatedAt := time.Unix(1755907200, 0)
 	mockFileInfo := mocks.NewMockFileInfo(ctrl)
 	mockFileInfo.EXPECT().ModTime().Return(fileCreatedAt).Times(2)
 	mockFileInfo.EXPECT().IsDir().Return(false)
+	mockFileInfo.EXPECT().Size().Return(int64(0))
 
 	mockEntry := mocks.NewMockDirEntry(ctrl)
 	mockEntry.EXPECT().Name().Return("file1.txt").Times(2)
@@ -33,10 +36,13 @@ func TestListFiles(t *testing.T) {
 
 	mockedResult := &File{
 		1755907200,
-		3.0,
+		days(3),
 		"file1.txt",
 		"foo/bar/file1.txt",
+		0,
 		false,
+		false,
+		mockFileInfo,
 		nil,
 	}
 
@@ -99,7 +105,7 @@ func TestListFilesFileHasError(t *testing.T) {
 	assert.Equal(t, "", file.path)
 	assert.Equal(t, false, file.isDir)
 	assert.Equal(t, int64(0), file.createdAt)
-	assert.Equal(t, float64(0), file.age)
+	assert.Equal(t, time.Duration(0), file.age)
 }
 
 func TestDeleteOldFile(t *testing.T) {
@@ -107,8 +113,8 @@ func TestDeleteOldFile(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockClock := mocks.NewMockClock(ctrl)
-	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(7.1).Times(1)
-	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(3.0).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(1)
 
 	fileToBeDeletedCreatedAt := time.Unix(1755561600, 0)
 	fileToBeKeptCreatedAt := time.Unix(1755907200, 0)
@@ -118,6 +124,7 @@ func TestDeleteOldFile(t *testing.T) {
 
 	mockFileInfoToBeDeleted.EXPECT().ModTime().Return(fileToBeDeletedCreatedAt).Times(2)
 	mockFileInfoToBeDeleted.EXPECT().IsDir().Return(false)
+	mockFileInfoToBeDeleted.EXPECT().Size().Return(int64(0))
 	mockEntryToBeDeleted.EXPECT().Name().Return("file1.txt").Times(2)
 	mockEntryToBeDeleted.EXPECT().Info().Return(mockFileInfoToBeDeleted, nil)
 
@@ -126,6 +133,7 @@ func TestDeleteOldFile(t *testing.T) {
 
 	mockFileInfoToBeKept.EXPECT().ModTime().Return(fileToBeKeptCreatedAt).Times(2)
 	mockFileInfoToBeKept.EXPECT().IsDir().Return(false)
+	mockFileInfoToBeKept.EXPECT().Size().Return(int64(0))
 	mockEntryToBeKept.EXPECT().Name().Return("file2.txt").Times(2)
 	mockEntryToBeKept.EXPECT().Info().Return(mockFileInfoToBeKept, nil)
 
@@ -141,10 +149,10 @@ func TestDeleteOldFile(t *testing.T) {
 		clock: mockClock,
 	}
 
-	result, errs := fileHandler.DeleteOldFiles(mockFS, "foo/bar", 7)
-	assert.Equal(t, 0, len(errs))
-	assert.Equal(t, 1, len(result))
-	assert.Equal(t, "foo/bar/file1.txt", result[0])
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "foo/bar", Age: config.Days(7)})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, 1, len(result.Deleted))
+	assert.Equal(t, "foo/bar/file1.txt", result.Deleted[0])
 }
 
 func TestDeleteOldFileHandlesListingError(t *testing.T) {
@@ -162,10 +170,10 @@ func TestDeleteOldFileHandlesListingError(t *testing.T) {
 		clock: mockClock,
 	}
 
-	result, errs := fileHandler.DeleteOldFiles(mockFS, "foo/bar", 7)
-	assert.Equal(t, 1, len(errs))
-	assert.Equal(t, mockError, errs[0])
-	assert.Equal(t, 0, len(result))
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "foo/bar", Age: config.Days(7)})
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, mockError, result.Errors[0])
+	assert.Equal(t, 0, len(result.Deleted))
 }
 
 func TestDeleteOldFileHandlesFileWithError(t *testing.T) {
@@ -176,11 +184,12 @@ func TestDeleteOldFileHandlesFileWithError(t *testing.T) {
 	fileToBeDeletedCreatedAt := time.Unix(1755561600, 0)
 
 	mockClock := mocks.NewMockClock(ctrl)
-	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(7.1).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(1)
 
 	mockFileInfoToBeDeleted := mocks.NewMockFileInfo(ctrl)
 	mockFileInfoToBeDeleted.EXPECT().ModTime().Return(fileToBeDeletedCreatedAt).Times(2)
 	mockFileInfoToBeDeleted.EXPECT().IsDir().Return(false).Times(1)
+	mockFileInfoToBeDeleted.EXPECT().Size().Return(int64(0))
 
 	mockEntryToBeDeleted := mocks.NewMockDirEntry(ctrl)
 	mockEntryToBeDeleted.EXPECT().Name().Return("file1.txt").Times(2)
@@ -202,11 +211,11 @@ func TestDeleteOldFileHandlesFileWithError(t *testing.T) {
 		clock: mockClock,
 	}
 
-	result, errs := fileHandler.DeleteOldFiles(mockFS, "foo/bar", 7)
-	assert.Equal(t, 1, len(errs))
-	assert.Equal(t, mockError, errs[0])
-	assert.Equal(t, 1, len(result))
-	assert.Equal(t, "foo/bar/file1.txt", result[0])
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "foo/bar", Age: config.Days(7)})
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, mockError, result.Errors[0])
+	assert.Equal(t, 1, len(result.Deleted))
+	assert.Equal(t, "foo/bar/file1.txt", result.Deleted[0])
 }
 
 func TestDeleteOldFileFailsOnDelete(t *testing.T) {
@@ -216,8 +225,8 @@ func TestDeleteOldFileFailsOnDelete(t *testing.T) {
 	mockError := errors.New("error")
 
 	mockClock := mocks.NewMockClock(ctrl)
-	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(7.1).Times(1)
-	mockClock.EXPECT().CalculateAge(int64(1755475200)).Return(8.0).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755475200)).Return(days(8)).Times(1)
 
 	fileToBeDeletedCreatedAt := time.Unix(1755561600, 0)
 	fileToBeKeptCreatedAt := time.Unix(1755475200, 0)
@@ -227,6 +236,7 @@ func TestDeleteOldFileFailsOnDelete(t *testing.T) {
 
 	mockFileInfoToBeDeleted.EXPECT().ModTime().Return(fileToBeDeletedCreatedAt).Times(2)
 	mockFileInfoToBeDeleted.EXPECT().IsDir().Return(false)
+	mockFileInfoToBeDeleted.EXPECT().Size().Return(int64(0))
 	mockEntryToBeDeleted.EXPECT().Name().Return("file1.txt").Times(2)
 	mockEntryToBeDeleted.EXPECT().Info().Return(mockFileInfoToBeDeleted, nil)
 
@@ -235,6 +245,7 @@ func TestDeleteOldFileFailsOnDelete(t *testing.T) {
 
 	mockFileInfoToBeDeletedWithError.EXPECT().ModTime().Return(fileToBeKeptCreatedAt).Times(2)
 	mockFileInfoToBeDeletedWithError.EXPECT().IsDir().Return(false)
+	mockFileInfoToBeDeletedWithError.EXPECT().Size().Return(int64(0))
 	mockEntryToBeDeletedWithError.EXPECT().Name().Return("file2.txt").Times(2)
 	mockEntryToBeDeletedWithError.EXPECT().Info().Return(mockFileInfoToBeDeletedWithError, nil)
 
@@ -250,9 +261,541 @@ func TestDeleteOldFileFailsOnDelete(t *testing.T) {
 		clock: mockClock,
 	}
 
-	result, errs := fileHandler.DeleteOldFiles(mockFS, "foo/bar", 7)
-	assert.Equal(t, 1, len(errs))
-	assert.Equal(t, mockError, errs[0])
-	assert.Equal(t, 1, len(result))
-	assert.Equal(t, "foo/bar/file1.txt", result[0])
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "foo/bar", Age: config.Days(7)})
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, mockError, result.Errors[0])
+	assert.Equal(t, 1, len(result.Deleted))
+	assert.Equal(t, "foo/bar/file1.txt", result.Deleted[0])
+}
+
+func mockEntry(ctrl *gomock.Controller, name string, modTime time.Time, isDir bool) *mocks.MockDirEntry {
+	return mockEntryWithSize(ctrl, name, modTime, isDir, 0)
+}
+
+func mockEntryWithSize(ctrl *gomock.Controller, name string, modTime time.Time, isDir bool, size int64) *mocks.MockDirEntry {
+	mockFileInfo := mocks.NewMockFileInfo(ctrl)
+	mockFileInfo.EXPECT().ModTime().Return(modTime).Times(2)
+	mockFileInfo.EXPECT().Size().Return(size)
+	mockFileInfo.EXPECT().IsDir().Return(isDir)
+
+	mockEntry := mocks.NewMockDirEntry(ctrl)
+	mockEntry.EXPECT().Name().Return(name).Times(2)
+	mockEntry.EXPECT().Info().Return(mockFileInfo, nil)
+
+	return mockEntry
+}
+
+// days returns the age of a file a number of days old
+func days(days float64) time.Duration {
+	return time.Duration(config.Days(days))
+}
+
+func TestWalkFilesRecursesIntoSubdirectories(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	modTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(4)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("foo").Return([]fs.DirEntry{
+		mockEntry(ctrl, "bar", modTime, true),
+		mockEntry(ctrl, "file1.txt", modTime, false),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("foo/bar").Return([]fs.DirEntry{
+		mockEntry(ctrl, "baz", modTime, true),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("foo/bar/baz").Return([]fs.DirEntry{
+		mockEntry(ctrl, "file2.txt", modTime, false),
+	}, nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	files, errs := fileHandler.WalkFiles(mockFS, "foo", 0)
+	paths := make([]string, 0)
+	for e := files.Front(); e != nil; e = e.Next() {
+		paths = append(paths, e.Value.(*File).path)
+	}
+
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, []string{"foo/bar", "foo/bar/baz", "foo/bar/baz/file2.txt", "foo/file1.txt"}, paths)
+}
+
+func TestWalkFilesStopsAtMaxDepth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	modTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(2)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("foo").Return([]fs.DirEntry{
+		mockEntry(ctrl, "bar", modTime, true),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("foo/bar").Return([]fs.DirEntry{
+		mockEntry(ctrl, "baz", modTime, true),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("foo/bar/baz").Times(0)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	files, errs := fileHandler.WalkFiles(mockFS, "foo", 2)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, 2, files.Len())
+}
+
+func TestWalkFilesCollectsSubdirectoryErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	modTime := time.Unix(1755907200, 0)
+	mockError := errors.New("permission denied")
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(2)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("foo").Return([]fs.DirEntry{
+		mockEntry(ctrl, "bar", modTime, true),
+		mockEntry(ctrl, "file1.txt", modTime, false),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("foo/bar").Return(nil, mockError).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	files, errs := fileHandler.WalkFiles(mockFS, "foo", 0)
+	assert.Equal(t, []error{mockError}, errs)
+	assert.Equal(t, 2, files.Len())
+}
+
+func TestDeleteOldFileRecursive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldModTime := time.Unix(1755561600, 0)
+	newModTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(2)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(1)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("logs").Return([]fs.DirEntry{
+		mockEntry(ctrl, "api", oldModTime, true),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("logs/api").Return([]fs.DirEntry{
+		mockEntry(ctrl, "old.log", oldModTime, false),
+		mockEntry(ctrl, "new.log", newModTime, false),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("logs/api/old.log").Return(nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "logs", Age: config.Days(7), Recursive: true})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"logs/api/old.log"}, result.Deleted)
+}
+
+func TestDeleteOldFileRemovesEmptyDirsBottomUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldModTime := time.Unix(1755561600, 0)
+	newModTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(3)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(1)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("spool").Return([]fs.DirEntry{
+		mockEntry(ctrl, "2025", oldModTime, true),
+		mockEntry(ctrl, "today", newModTime, true),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("spool/2025").Return([]fs.DirEntry{
+		mockEntry(ctrl, "08", oldModTime, true),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("spool/2025/08").Return([]fs.DirEntry{
+		mockEntry(ctrl, "upload.bin", oldModTime, false),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("spool/today").Return([]fs.DirEntry{}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("spool/2025/08/upload.bin").Return(nil).Times(1)
+
+	gomock.InOrder(
+		mockFS.EXPECT().ReadDir("spool/2025/08").Return([]fs.DirEntry{}, nil).Times(1),
+		mockFS.EXPECT().DeleteFile("spool/2025/08").Return(nil).Times(1),
+		mockFS.EXPECT().ReadDir("spool/2025").Return([]fs.DirEntry{}, nil).Times(1),
+		mockFS.EXPECT().DeleteFile("spool/2025").Return(nil).Times(1),
+	)
+	mockFS.EXPECT().DeleteFile("spool/today").Times(0)
+	mockFS.EXPECT().DeleteFile("spool").Times(0)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "spool", Age: config.Days(7), Recursive: true, RemoveEmptyDirs: true})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"spool/2025/08/upload.bin"}, result.Deleted)
+	assert.Equal(t, []string{"spool/2025/08", "spool/2025"}, result.RemovedDirs)
+}
+
+func TestDeleteOldFileKeepsNonEmptyDirs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldModTime := time.Unix(1755561600, 0)
+	newModTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(1)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("spool").Return([]fs.DirEntry{
+		mockEntry(ctrl, "2025", oldModTime, true),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("spool/2025").Return([]fs.DirEntry{
+		mockEntry(ctrl, "upload.bin", newModTime, false),
+	}, nil).Times(1)
+
+	mockLeftEntry := mocks.NewMockDirEntry(ctrl)
+	mockLeftEntry.EXPECT().Name().Return("upload.bin").Times(1)
+	mockFS.EXPECT().ReadDir("spool/2025").Return([]fs.DirEntry{mockLeftEntry}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile(gomock.Any()).Times(0)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "spool", Age: config.Days(7), Recursive: true, RemoveEmptyDirs: true})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, 0, len(result.Deleted))
+	assert.Equal(t, 0, len(result.RemovedDirs))
+}
+
+func TestDeleteOldFileSkipsFilesFilteredByPatterns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldModTime := time.Unix(1755561600, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(3)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("logs").Return([]fs.DirEntry{
+		mockEntry(ctrl, "app.log", oldModTime, false),
+		mockEntry(ctrl, "current.log", oldModTime, false),
+		mockEntry(ctrl, ".keep", oldModTime, false),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("logs/app.log").Return(nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{
+		Path:    "logs",
+		Age:     config.Days(7),
+		Include: []string{"**/*.log"},
+		Exclude: []string{"**/current.log"},
+	})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"logs/app.log"}, result.Deleted)
+	assert.Equal(t, []string{"logs/current.log", "logs/.keep"}, result.Skipped)
+}
+
+func TestDeleteOldFileKeepsLatest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755302400)).Return(days(10)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755388800)).Return(days(9)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755475200)).Return(days(8)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(1)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("dumps").Return([]fs.DirEntry{
+		mockEntry(ctrl, "dump-3.sql", time.Unix(1755475200, 0), false),
+		mockEntry(ctrl, "dump-1.sql", time.Unix(1755302400, 0), false),
+		mockEntry(ctrl, "dump-4.sql", time.Unix(1755907200, 0), false),
+		mockEntry(ctrl, "dump-2.sql", time.Unix(1755388800, 0), false),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("dumps/dump-1.sql").Return(nil).Times(1)
+	mockFS.EXPECT().DeleteFile("dumps/dump-2.sql").Return(nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "dumps", KeepLatest: 1, Age: config.Days(8.5)})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"dumps/dump-1.sql", "dumps/dump-2.sql"}, result.Deleted)
+}
+
+func TestDeleteOldFileKeepsLatestWhateverTheirAge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755302400)).Return(days(10)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755388800)).Return(days(9)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755475200)).Return(days(8)).Times(1)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("dumps").Return([]fs.DirEntry{
+		mockEntry(ctrl, "dump-1.sql", time.Unix(1755302400, 0), false),
+		mockEntry(ctrl, "dump-2.sql", time.Unix(1755388800, 0), false),
+		mockEntry(ctrl, "dump-3.sql", time.Unix(1755475200, 0), false),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("dumps/dump-1.sql").Return(nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{Path: "dumps", KeepLatest: 2, Age: config.Days(1)})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"dumps/dump-1.sql"}, result.Deleted)
+}
+
+func TestEnforceQuotaDeletesOldestFirst(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(days(1)).Times(4)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("cache").Return([]fs.DirEntry{
+		mockEntryWithSize(ctrl, "c.bin", time.Unix(1755475200, 0), false, 400),
+		mockEntryWithSize(ctrl, "a.bin", time.Unix(1755302400, 0), false, 300),
+		mockEntryWithSize(ctrl, "d.bin", time.Unix(1755561600, 0), false, 200),
+		mockEntryWithSize(ctrl, "b.bin", time.Unix(1755388800, 0), false, 300),
+	}, nil).Times(1)
+
+	gomock.InOrder(
+		mockFS.EXPECT().DeleteFile("cache/a.bin").Return(nil).Times(1),
+		mockFS.EXPECT().DeleteFile("cache/b.bin").Return(nil).Times(1),
+	)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.EnforceQuota(mockFS, config.WatchedDirectory{Path: "cache", MaxTotalSize: 650})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"cache/a.bin", "cache/b.bin"}, result.Deleted)
+}
+
+func TestEnforceQuotaSparesKeptAndExcludedFiles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(days(1)).Times(3)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("cache").Return([]fs.DirEntry{
+		mockEntryWithSize(ctrl, "a.keep", time.Unix(1755302400, 0), false, 500),
+		mockEntryWithSize(ctrl, "b.bin", time.Unix(1755388800, 0), false, 300),
+		mockEntryWithSize(ctrl, "c.bin", time.Unix(1755475200, 0), false, 300),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("cache/b.bin").Return(nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.EnforceQuota(mockFS, config.WatchedDirectory{
+		Path:         "cache",
+		MaxTotalSize: 100,
+		KeepLatest:   1,
+		Exclude:      []string{"*.keep"},
+	})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"cache/b.bin"}, result.Deleted)
+	assert.Equal(t, []string{"cache/a.keep"}, result.Skipped)
+}
+
+func TestCleanWithQuotaOnlyIgnoresAge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(days(30)).Times(2)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("cache").Return([]fs.DirEntry{
+		mockEntryWithSize(ctrl, "a.bin", time.Unix(1755302400, 0), false, 100),
+		mockEntryWithSize(ctrl, "b.bin", time.Unix(1755388800, 0), false, 100),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile(gomock.Any()).Times(0)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.Clean(mockFS, config.WatchedDirectory{Path: "cache", MaxTotalSize: 200})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, 0, len(result.Deleted))
+}
+
+func TestCleanDeletesByAgeThenBySize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755302400)).Return(days(10)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755388800)).Return(days(2)).Times(1)
+	mockClock.EXPECT().CalculateAge(int64(1755475200)).Return(days(1)).Times(1)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("cache").Return([]fs.DirEntry{
+		mockEntryWithSize(ctrl, "a.bin", time.Unix(1755302400, 0), false, 100),
+		mockEntryWithSize(ctrl, "b.bin", time.Unix(1755388800, 0), false, 100),
+		mockEntryWithSize(ctrl, "c.bin", time.Unix(1755475200, 0), false, 100),
+	}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile("cache/a.bin").Return(nil).Times(1)
+	mockFS.EXPECT().DeleteFile("cache/b.bin").Return(nil).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.Clean(mockFS, config.WatchedDirectory{Path: "cache", Age: config.Days(7), MaxTotalSize: 150})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"cache/a.bin", "cache/b.bin"}, result.Deleted)
+}
+
+func TestFreeSpaceDoesNothingAboveLowWatermark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().Statfs("data").Return(filesystem.DiskUsage{Total: 1000, Free: 150}, nil).Times(1)
+	mockFS.EXPECT().ReadDir(gomock.Any()).Times(0)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.FreeSpace(mockFS, config.WatchedDirectory{
+		Path:      "data",
+		Watermark: &config.Watermark{Low: config.Threshold{Percent: 10}, High: config.Threshold{Percent: 30}},
+	})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, 0, len(result.Deleted))
+}
+
+func TestFreeSpaceDeletesOldestUntilHighWatermark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(gomock.Any()).Return(days(1)).Times(3)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().Statfs("data").Return(filesystem.DiskUsage{Total: 1000, Free: 50}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("data").Return([]fs.DirEntry{
+		mockEntryWithSize(ctrl, "c.bin", time.Unix(1755475200, 0), false, 200),
+		mockEntryWithSize(ctrl, "a.bin", time.Unix(1755302400, 0), false, 150),
+		mockEntryWithSize(ctrl, "b.bin", time.Unix(1755388800, 0), false, 150),
+	}, nil).Times(1)
+
+	gomock.InOrder(
+		mockFS.EXPECT().DeleteFile("data/a.bin").Return(nil).Times(1),
+		mockFS.EXPECT().DeleteFile("data/b.bin").Return(nil).Times(1),
+	)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.FreeSpace(mockFS, config.WatchedDirectory{
+		Path:      "data",
+		Watermark: &config.Watermark{Low: config.Threshold{Percent: 10}, High: config.Threshold{Bytes: 300}},
+	})
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"data/a.bin", "data/b.bin"}, result.Deleted)
+}
+
+func TestFreeSpaceHandlesStatfsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockError := errors.New("unsupported")
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().Statfs("data").Return(filesystem.DiskUsage{}, mockError).Times(1)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	result := fileHandler.FreeSpace(mockFS, config.WatchedDirectory{
+		Path:      "data",
+		Watermark: &config.Watermark{Low: config.Threshold{Percent: 10}},
+	})
+	assert.Equal(t, []error{mockError}, result.Errors)
+}
+
+func TestDeleteOldFileDryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	oldModTime := time.Unix(1755561600, 0)
+	newModTime := time.Unix(1755907200, 0)
+
+	mockClock := mocks.NewMockClock(ctrl)
+	mockClock.EXPECT().CalculateAge(int64(1755561600)).Return(days(7.1)).Times(2)
+	mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(3)).Times(1)
+
+	mockFS := mocks.NewMockFileSystem(ctrl)
+	mockFS.EXPECT().ReadDir("spool").Return([]fs.DirEntry{
+		mockEntry(ctrl, "2025", oldModTime, true),
+		mockEntry(ctrl, "new.bin", newModTime, false),
+	}, nil).Times(1)
+	mockFS.EXPECT().ReadDir("spool/2025").Return([]fs.DirEntry{
+		mockEntry(ctrl, "old.bin", oldModTime, false),
+	}, nil).Times(1)
+
+	mockLeftEntry := mocks.NewMockDirEntry(ctrl)
+	mockLeftEntry.EXPECT().Name().Return("old.bin").Times(1)
+	mockFS.EXPECT().ReadDir("spool/2025").Return([]fs.DirEntry{mockLeftEntry}, nil).Times(1)
+	mockFS.EXPECT().DeleteFile(gomock.Any()).Times(0)
+
+	fileHandler := FileHandler{
+		clock: mockClock,
+	}
+
+	dryRun := true
+	result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{
+		Path:            "spool",
+		Age:             config.Days(7),
+		Recursive:       true,
+		RemoveEmptyDirs: true,
+		DryRun:          &dryRun,
+	})
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{"spool/2025/old.bin"}, result.Deleted)
+	assert.Equal(t, []string{"spool/2025"}, result.RemovedDirs)
 }
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fileman/config"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// isSelected tells whether a file passes the include and exclude patterns
+// of the watched directory, exclude taking precedence. A directory without
+// include patterns selects every file that isn't excluded.
+func isSelected(directory config.WatchedDirectory, path string) (bool, error) {
+	excluded, err := isExcluded(directory, path)
+
+	if err != nil || excluded {
+		return false, err
+	}
+
+	if len(directory.Include) == 0 {
+		return true, nil
+	}
+
+	return matchesAny(directory.Include, directory.Path, path)
+}
+
+// isExcluded tells whether a path matches one of the exclude patterns
+// of the watched directory.
+func isExcluded(directory config.WatchedDirectory, path string) (bool, error) {
+	return matchesAny(directory.Exclude, directory.Path, path)
+}
+
+// matchesAny matches the path, taken relative to root and with forward
+// slashes, against doublestar patterns such as **/*.log. A file compressed
+// by the compress action also matches the patterns of its original name,
+// so that app.log.gz is still matched by *.log.
+func matchesAny(patterns []string, root string, path string) (bool, error) {
+	if len(patterns) == 0 {
+		return false, nil
+	}
+
+	if original, ok := uncompressedPath(path); ok {
+		if matched, err := matchesAny(patterns, root, original); err != nil || matched {
+			return matched, err
+		}
+	}
+
+	relative, err := filepath.Rel(root, path)
+
+	if err != nil {
+		return false, err
+	}
+
+	relative = filepath.ToSlash(relative)
+
+	for _, pattern := range patterns {
+		matched, err := doublestar.Match(pattern, relative)
+
+		if err != nil {
+			return false, err
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
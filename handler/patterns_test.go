@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"fileman/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsSelected(t *testing.T) {
+	directory := config.WatchedDirectory{
+		Path:    "/files/logs",
+		Include: []string{"**/*.log"},
+		Exclude: []string{"**/current.log", ".keep"},
+	}
+
+	cases := map[string]bool{
+		"/files/logs/app.log":              true,
+		"/files/logs/api/2025/app.log":     true,
+		"/files/logs/api/current.log":      false,
+		"/files/logs/current.log":          false,
+		"/files/logs/.keep":                false,
+		"/files/logs/config.json":          false,
+		"/files/logs/api/2025/app.log.bak": false,
+		"/files/logs/app.log.gz":           true,
+		"/files/logs/api/app.log.ZST":      true,
+		"/files/logs/current.log.gz":       false,
+		"/files/logs/config.json.gz":       false,
+		"/files/logs/.gz":                  false,
+	}
+
+	for path, expected := range cases {
+		selected, err := isSelected(directory, path)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, selected, path)
+	}
+}
+
+func TestIsSelectedWithoutPatterns(t *testing.T) {
+	selected, err := isSelected(config.WatchedDirectory{Path: "/files"}, "/files/any/file.txt")
+
+	assert.Nil(t, err)
+	assert.True(t, selected)
+}
+
+func TestIsSelectedBadPattern(t *testing.T) {
+	directory := config.WatchedDirectory{
+		Path:    "/files",
+		Exclude: []string{"[*.log"},
+	}
+
+	selected, err := isSelected(directory, "/files/app.log")
+
+	assert.Error(t, err)
+	assert.False(t, selected)
+}
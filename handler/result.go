@@ -0,0 +1,81 @@
+package handler
+
+import "sync"
+
+// Result holds the outcome of a cleanup pass over a watched directory.
+// Compressed lists the files replaced by a compressed copy, Trashed the ones
+// moved to the quarantine directory, Purged the ones deleted from it and
+// Archived the ones deleted once stored in the Archive file. For dry runs,
+// they list what a real run would do.
+type Result struct {
+	DryRun      bool
+	Compressed  []string
+	Deleted     []string
+	Trashed     []string
+	Purged      []string
+	Archive     string
+	Archived    []string
+	RemovedDirs []string
+	Skipped     []string
+	Errors      []error
+
+	pending []*File
+}
+
+func NewResult(dryRun bool) *Result {
+	return &Result{
+		DryRun:      dryRun,
+		Compressed:  make([]string, 0),
+		Deleted:     make([]string, 0),
+		Trashed:     make([]string, 0),
+		Purged:      make([]string, 0),
+		Archived:    make([]string, 0),
+		RemovedDirs: make([]string, 0),
+		Skipped:     make([]string, 0),
+		Errors:      make([]error, 0),
+	}
+}
+
+// IsEmpty tells whether the pass neither changed anything nor failed
+func (r Result) IsEmpty() bool {
+	return len(r.Compressed) == 0 && len(r.Deleted) == 0 && len(r.Trashed) == 0 && len(r.Purged) == 0 && len(r.Archived) == 0 &&
+		len(r.RemovedDirs) == 0 && len(r.Errors) == 0
+}
+
+// Summary adds up the results of the passes over the watched directories
+// since startup, leaving dry runs aside
+type Summary struct {
+	mutex  sync.Mutex
+	totals Totals
+}
+
+// Totals counts the files removed, whether deleted, trashed or archived,
+// the ones compressed and the errors met
+type Totals struct {
+	Removed    int
+	Compressed int
+	Errors     int
+}
+
+// Add counts the outcome of a pass
+func (s *Summary) Add(result Result) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.totals.Errors += len(result.Errors)
+
+	if result.DryRun {
+		return
+	}
+
+	s.totals.Removed += len(result.Deleted) + len(result.Trashed) + len(result.Archived)
+	s.totals.Compressed += len(result.Compressed)
+}
+
+// Totals returns the counts so far
+func (s *Summary) Totals() Totals {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.totals
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSummaryAddsUpResults(t *testing.T) {
+	summary := Summary{}
+
+	summary.Add(Result{
+		Deleted:    []string{"a", "b"},
+		Trashed:    []string{"c"},
+		Archived:   []string{"d"},
+		Compressed: []string{"e"},
+		Errors:     []error{errors.New("f")},
+	})
+	summary.Add(Result{
+		DryRun:     true,
+		Deleted:    []string{"g"},
+		Compressed: []string{"h"},
+		Errors:     []error{errors.New("i")},
+	})
+	summary.Add(*NewResult(false))
+
+	assert.Equal(t, Totals{Removed: 4, Compressed: 1, Errors: 2}, summary.Totals())
+}
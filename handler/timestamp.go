@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fileman/config"
+	"fileman/fs"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ageSource tells the timestamp a file age is computed from: its
+// modification, access or status change time, or a date in its name.
+type ageSource struct {
+	kind    string
+	pattern *regexp.Regexp
+	layout  string
+}
+
+func newAgeSource(directory config.WatchedDirectory) (*ageSource, error) {
+	switch directory.AgeSource {
+	case "", "mtime":
+		return &ageSource{kind: "mtime"}, nil
+	case "atime", "ctime":
+		return &ageSource{kind: directory.AgeSource}, nil
+	case "filename":
+		if directory.Filename == nil {
+			return nil, fmt.Errorf("age source filename of %s needs a filename pattern and layout", directory.Path)
+		}
+
+		pattern, err := regexp.Compile(directory.Filename.Pattern)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &ageSource{kind: "filename", pattern: pattern, layout: directory.Filename.Layout}, nil
+	}
+
+	return nil, fmt.Errorf("unknown age source %q", directory.AgeSource)
+}
+
+// time returns the timestamp of a listed file
+func (s ageSource) time(file *File) (time.Time, error) {
+	switch s.kind {
+	case "atime":
+		if atime, ok := fs.AccessTime(file.info); ok {
+			return atime, nil
+		}
+	case "ctime":
+		if ctime, ok := fs.ChangeTime(file.info); ok {
+			return ctime, nil
+		}
+	case "filename":
+		return filenameTime(s.pattern, s.layout, file.name)
+	default:
+		return time.Unix(file.createdAt, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%s of %s isn't available on this platform", s.kind, file.path)
+}
+
+// filenameTime extracts a timestamp from a file name. The pattern match
+// is parsed with the given Go time layout, restricted to its capture group
+// named date when it has one, e.g. `app-(?P<date>\d{4}-\d{2}-\d{2})\.log`
+// along with the layout 2006-01-02.
+func filenameTime(pattern *regexp.Regexp, layout string, name string) (time.Time, error) {
+	match := pattern.FindStringSubmatch(name)
+
+	if match == nil {
+		return time.Time{}, fmt.Errorf("no date matching %q in file name %q", pattern.String(), name)
+	}
+
+	value := match[0]
+	if index := pattern.SubexpIndex("date"); index != -1 {
+		value = match[index]
+	}
+
+	timestamp, err := time.ParseInLocation(layout, value, time.Local)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date in file name %q: %w", name, err)
+	}
+
+	return timestamp, nil
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fileman/config"
+	"fileman/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"io/fs"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilenameTime(t *testing.T) {
+	pattern := regexp.MustCompile(`app-\d{4}-\d{2}-\d{2}`)
+
+	date, err := filenameTime(pattern, "app-2006-01-02", "app-2025-08-22.log")
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2025, 8, 22, 0, 0, 0, 0, time.Local), date)
+
+	_, err = filenameTime(pattern, "app-2006-01-02", "app-2025-13-22.log")
+	assert.Error(t, err)
+
+	_, err = filenameTime(pattern, "app-2006-01-02", "app.log")
+	assert.Error(t, err)
+}
+
+func TestNewAgeSourceRejectsInvalidConfig(t *testing.T) {
+	_, err := newAgeSource(config.WatchedDirectory{AgeSource: "birthtime"})
+	assert.Error(t, err)
+
+	_, err = newAgeSource(config.WatchedDirectory{AgeSource: "filename"})
+	assert.Error(t, err)
+
+	_, err = newAgeSource(config.WatchedDirectory{
+		AgeSource: "filename",
+		Filename:  &config.FilenameDate{Pattern: "(", Layout: "2006"},
+	})
+	assert.Error(t, err)
+}
+
+func TestDeleteOldFileAgeFromFilename(t *testing.T) {
+	for _, onError := range []string{"", "skip"} {
+		ctrl := gomock.NewController(t)
+
+		modTime := time.Unix(1755907200, 0)
+		oldDate := time.Date(2025, 8, 1, 0, 0, 0, 0, time.Local).Unix()
+		newDate := time.Date(2025, 8, 22, 0, 0, 0, 0, time.Local).Unix()
+
+		mockClock := mocks.NewMockClock(ctrl)
+		mockClock.EXPECT().CalculateAge(int64(1755907200)).Return(days(0)).Times(3)
+		mockClock.EXPECT().CalculateAge(oldDate).Return(days(21)).Times(1)
+		mockClock.EXPECT().CalculateAge(newDate).Return(days(0)).Times(1)
+
+		mockFS := mocks.NewMockFileSystem(ctrl)
+		mockFS.EXPECT().ReadDir("logs").Return([]fs.DirEntry{
+			mockEntry(ctrl, "app-2025-08-01.log", modTime, false),
+			mockEntry(ctrl, "app-2025-08-22.log", modTime, false),
+			mockEntry(ctrl, "app.log", modTime, false),
+		}, nil).Times(1)
+		mockFS.EXPECT().DeleteFile("logs/app-2025-08-01.log").Return(nil).Times(1)
+
+		fileHandler := FileHandler{
+			clock: mockClock,
+		}
+
+		result := fileHandler.DeleteOldFiles(mockFS, config.WatchedDirectory{
+			Path:      "logs",
+			Age:       config.Days(7),
+			AgeSource: "filename",
+			Filename: &config.FilenameDate{
+				Pattern: `app-(?P<date>\d{4}-\d{2}-\d{2})\.log`,
+				Layout:  "2006-01-02",
+				OnError: onError,
+			},
+		})
+		assert.Equal(t, []string{"logs/app-2025-08-01.log"}, result.Deleted)
+
+		if onError == "skip" {
+			assert.Equal(t, 0, len(result.Errors))
+			assert.Equal(t, []string{"logs/app.log"}, result.Skipped)
+		} else {
+			assert.Equal(t, 1, len(result.Errors))
+			assert.Equal(t, 0, len(result.Skipped))
+		}
+
+		ctrl.Finish()
+	}
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fileman/config"
+	"fileman/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Trashed files are moved, under the quarantine directory, to files/ with
+// their path relative to the watched directory, while info/ holds for each
+// of them a .trashinfo file telling where it came from, as desktop trashes
+// do. The modification time of the .trashinfo file is the time the file was
+// trashed, which the purge relies on.
+const (
+	trashFilesDir   = "files"
+	trashInfoDir    = "info"
+	trashInfoSuffix = ".trashinfo"
+)
+
+// trashInfo records where a trashed file came from
+type trashInfo struct {
+	Path      string    `json:"path"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// PurgeTrash permanently deletes the files of the quarantine directory of
+// the watched directory that were trashed more than its age ago.
+func (f FileHandler) PurgeTrash(fs fs.FileSystem, directory config.WatchedDirectory) Result {
+	result := NewResult(directory.IsDryRun())
+	f.purgeTrash(fs, directory, result)
+
+	return *result
+}
+
+// trashFile moves a file to the quarantine directory of the watched
+// directory. A file already trashed under the same path is kept, the new
+// one being suffixed with the time it was trashed.
+func (f FileHandler) trashFile(fs fs.FileSystem, file *File, directory config.WatchedDirectory) error {
+	if directory.Trash == nil || directory.Trash.Path == "" {
+		return errors.New("trash action of " + directory.Path + " needs a trash path")
+	}
+
+	relative, err := filepath.Rel(directory.Path, file.path)
+
+	if err != nil {
+		return err
+	}
+
+	now := f.clock.Unix()
+	target := filepath.Join(directory.Trash.Path, trashFilesDir, relative)
+
+	if _, err := fs.Stat(target); err == nil {
+		relative = relative + "." + time.Unix(now, 0).UTC().Format("20060102T150405")
+		target = filepath.Join(directory.Trash.Path, trashFilesDir, relative)
+	}
+
+	infoPath := filepath.Join(directory.Trash.Path, trashInfoDir, relative+trashInfoSuffix)
+	info, err := json.Marshal(trashInfo{Path: file.path, DeletedAt: time.Unix(now, 0).UTC()})
+
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{filepath.Dir(target), filepath.Dir(infoPath)} {
+		if err := fs.MkdirAll(dir); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.WriteFile(infoPath, info); err != nil {
+		return err
+	}
+
+	if err := fs.Rename(file.path, target); err != nil {
+		fs.DeleteFile(infoPath)
+		return err
+	}
+
+	return nil
+}
+
+// purgeTrash deletes the trashed files, along with their .trashinfo, that
+// were trashed more than the trash age ago, and then the directories of
+// the quarantine left empty.
+func (f FileHandler) purgeTrash(fs fs.FileSystem, directory config.WatchedDirectory, result *Result) {
+	if directory.Trash == nil || directory.Trash.Age <= 0 {
+		return
+	}
+
+	infoRoot := filepath.Join(directory.Trash.Path, trashInfoDir)
+	filesRoot := filepath.Join(directory.Trash.Path, trashFilesDir)
+	infoFiles, errs := f.WalkFiles(fs, infoRoot, 0)
+
+	for _, err := range errs {
+		if !errors.Is(err, os.ErrNotExist) {
+			result.Errors = append(result.Errors, err)
+		}
+	}
+
+	for e := infoFiles.Front(); e != nil; e = e.Next() {
+		file := e.Value.(*File)
+
+		if file.error != nil {
+			result.Errors = append(result.Errors, file.error)
+			continue
+		}
+
+		if file.isDir || !strings.HasSuffix(file.name, trashInfoSuffix) || file.age <= time.Duration(directory.Trash.Age) {
+			continue
+		}
+
+		relative, err := filepath.Rel(infoRoot, strings.TrimSuffix(file.path, trashInfoSuffix))
+
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		trashed := filepath.Join(filesRoot, relative)
+
+		if !directory.IsDryRun() {
+			if err := fs.DeleteFile(trashed); err != nil && !errors.Is(err, os.ErrNotExist) {
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+
+			if err := fs.DeleteFile(file.path); err != nil {
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+		}
+
+		file.deleted = true
+		result.Purged = append(result.Purged, trashed)
+	}
+
+	if directory.IsDryRun() {
+		return
+	}
+
+	// Directories of the quarantine are removed as soon as they are empty,
+	// whatever their age, hence the negative threshold
+	for _, root := range []string{infoRoot, filesRoot} {
+		files, _ := f.WalkFiles(fs, root, 0)
+		empties := NewResult(false)
+		f.removeEmptyDirs(fs, files, config.WatchedDirectory{Path: root, Age: -1}, empties)
+		result.Errors = append(result.Errors, empties.Errors...)
+	}
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"fileman/clock"
+	"fileman/config"
+	filesystem "fileman/fs"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeAgedFile(t *testing.T, path string, age time.Duration) {
+	t.Helper()
+
+	modTime := time.Now().Add(-age)
+
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.Nil(t, os.WriteFile(path, []byte(path), 0o644))
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestCleanMovesFilesToTrash(t *testing.T) {
+	root := t.TempDir()
+	watched := filepath.Join(root, "logs")
+	trash := filepath.Join(root, "trash")
+
+	writeAgedFile(t, filepath.Join(watched, "api", "old.log"), 10*24*time.Hour)
+	writeAgedFile(t, filepath.Join(watched, "api", "new.log"), time.Hour)
+	writeAgedFile(t, filepath.Join(trash, "files", "api", "old.log"), time.Hour)
+
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path:      watched,
+		Age:       config.Days(7),
+		Recursive: true,
+		Action:    "trash",
+		Trash:     &config.Trash{Path: trash},
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, 0, len(result.Deleted))
+	assert.Equal(t, []string{filepath.Join(watched, "api", "old.log")}, result.Trashed)
+	assert.NoFileExists(t, filepath.Join(watched, "api", "old.log"))
+	assert.FileExists(t, filepath.Join(watched, "api", "new.log"))
+	assert.FileExists(t, filepath.Join(trash, "files", "api", "old.log"))
+
+	trashedFiles, _ := filepath.Glob(filepath.Join(trash, "files", "api", "old.log.*"))
+	assert.Equal(t, 1, len(trashedFiles))
+
+	infoFiles, _ := filepath.Glob(filepath.Join(trash, "info", "api", "old.log.*.trashinfo"))
+	assert.Equal(t, 1, len(infoFiles))
+
+	content, err := os.ReadFile(infoFiles[0])
+	assert.Nil(t, err)
+
+	info := trashInfo{}
+	assert.Nil(t, json.Unmarshal(content, &info))
+	assert.Equal(t, filepath.Join(watched, "api", "old.log"), info.Path)
+	assert.WithinDuration(t, time.Now(), info.DeletedAt, time.Minute)
+}
+
+func TestPurgeTrashDeletesExpiredFiles(t *testing.T) {
+	root := t.TempDir()
+	trash := filepath.Join(root, "trash")
+
+	writeAgedFile(t, filepath.Join(trash, "files", "api", "old.log"), 40*24*time.Hour)
+	writeAgedFile(t, filepath.Join(trash, "info", "api", "old.log.trashinfo"), 31*24*time.Hour)
+	writeAgedFile(t, filepath.Join(trash, "files", "recent.log"), 40*24*time.Hour)
+	writeAgedFile(t, filepath.Join(trash, "info", "recent.log.trashinfo"), 24*time.Hour)
+
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.PurgeTrash(filesystem.FS{}, config.WatchedDirectory{
+		Path:  filepath.Join(root, "logs"),
+		Trash: &config.Trash{Path: trash, Age: config.Days(30)},
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{filepath.Join(trash, "files", "api", "old.log")}, result.Purged)
+	assert.NoDirExists(t, filepath.Join(trash, "files", "api"))
+	assert.NoDirExists(t, filepath.Join(trash, "info", "api"))
+	assert.FileExists(t, filepath.Join(trash, "files", "recent.log"))
+	assert.FileExists(t, filepath.Join(trash, "info", "recent.log.trashinfo"))
+}
+
+func TestCleanDryRunLeavesFilesOutOfTrash(t *testing.T) {
+	root := t.TempDir()
+	watched := filepath.Join(root, "logs")
+	trash := filepath.Join(root, "trash")
+
+	writeAgedFile(t, filepath.Join(watched, "old.log"), 10*24*time.Hour)
+
+	dryRun := true
+	fileHandler := New(clock.RealClock{})
+	result := fileHandler.Clean(filesystem.FS{}, config.WatchedDirectory{
+		Path:   watched,
+		Age:    config.Days(7),
+		Action: "trash",
+		Trash:  &config.Trash{Path: trash},
+		DryRun: &dryRun,
+	})
+
+	assert.Equal(t, 0, len(result.Errors))
+	assert.Equal(t, []string{filepath.Join(watched, "old.log")}, result.Trashed)
+	assert.FileExists(t, filepath.Join(watched, "old.log"))
+	assert.NoDirExists(t, trash)
+}
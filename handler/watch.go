@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fileman/config"
+	"fileman/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Watch removes the files of the watched directory as soon as they grow
+// older than its age, instead of waiting for a scheduled pass. The files
+// present at startup are listed, the others followed through the events of
+// the filesystem, and their deadlines kept in a min-heap waited on with the
+// clock. A file is dated again when its deadline comes, in case it has been
+// modified meanwhile. Files expiring outside the maintenance windows of the
+// directory are put off until the next window opens. Each batch of
+// removals goes through guard, which tells whether it ran, so that it
+// doesn't overlap the passes over the directory. A batch that didn't run
+// is retried after guardRetryDelay, and a file whose removal failed is
+// retried with a backoff. The outcome of each batch is passed to report.
+// It returns once the context is done.
+func (f FileHandler) Watch(ctx context.Context, fs fs.FileSystem, directory config.WatchedDirectory, guard func(task func()) bool, report func(Result)) {
+	result := NewResult(directory.IsDryRun())
+	location, err := directory.Location()
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		report(*result)
+		return
+	}
+
+	source, err := newAgeSource(directory)
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		report(*result)
+		return
+	}
+
+	events, err := fs.Watch(ctx, directory.Path, directory.Recursive)
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		report(*result)
+		return
+	}
+
+	queue := newExpiryQueue()
+	_, candidates := f.collectFiles(fs, directory, result)
+
+	for _, file := range candidates {
+		queue.schedule(file.path, expiresAt(file, directory))
+	}
+
+	for {
+		f.expireDue(fs, queue, source, location, directory, guard, result)
+
+		if !result.IsEmpty() {
+			report(*result)
+		}
+
+		result = NewResult(directory.IsDryRun())
+
+		var timer <-chan time.Time
+		if next := queue.next(); next != nil {
+			timer = f.clock.After(next.deadline.Sub(f.clock.Now()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer:
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			switch {
+			case event.Err != nil:
+				result.Errors = append(result.Errors, event.Err)
+			case event.Removed:
+				queue.cancel(event.Path)
+			default:
+				f.track(fs, queue, event.Path, source, directory, result)
+			}
+		}
+	}
+}
+
+// track schedules the expiry of a file created or modified in the watched
+// directory, unless the directory doesn't select it
+func (f FileHandler) track(fs fs.FileSystem, queue *expiryQueue, path string, source *ageSource, directory config.WatchedDirectory, result *Result) {
+	if !withinDepth(directory, path) {
+		return
+	}
+
+	file, err := f.statFile(fs, path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		queue.cancel(path)
+		return
+	}
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return
+	}
+
+	if file.isDir {
+		return
+	}
+
+	selected, err := isSelected(directory, path)
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return
+	}
+
+	dated := false
+	if selected {
+		dated, err = f.date(file, source, directory)
+	}
+
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+	}
+
+	if !dated {
+		queue.cancel(path)
+		return
+	}
+
+	queue.schedule(path, expiresAt(file, directory))
+}
+
+// guardRetryDelay is how long the removal of files put off by the guard of
+// the directory, as a pass over it is going, waits to be retried
+const guardRetryDelay = time.Minute
+
+// expireDue removes the files whose deadline has come, or puts them off
+// until the next maintenance window of the directory when it is closed, or
+// until the guard lets them be removed
+func (f FileHandler) expireDue(fs fs.FileSystem, queue *expiryQueue, source *ageSource, location *time.Location, directory config.WatchedDirectory, guard func(task func()) bool, result *Result) {
+	now := f.clock.Now()
+	paths := queue.due(now)
+
+	if len(paths) == 0 {
+		return
+	}
+
+	if !config.InWindow(directory.Windows, now.In(location)) {
+		opening := config.NextWindow(directory.Windows, now.In(location))
+
+		for _, path := range paths {
+			queue.schedule(path, opening)
+		}
+
+		return
+	}
+
+	ran := guard(func() {
+		f.expire(fs, queue, paths, now, source, directory, result)
+	})
+
+	if !ran {
+		for _, path := range paths {
+			queue.schedule(path, now.Add(guardRetryDelay))
+		}
+	}
+}
+
+// expire removes the files of the given paths that expired, once dated
+// again, and retries the ones that couldn't be
+func (f FileHandler) expire(fs fs.FileSystem, queue *expiryQueue, paths []string, now time.Time, source *ageSource, directory config.WatchedDirectory, result *Result) {
+	removed := make([]*File, 0)
+
+	for _, path := range paths {
+		file, err := f.statFile(fs, path)
+
+		if errors.Is(err, os.ErrNotExist) {
+			queue.forget(path)
+			continue
+		}
+
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			queue.retry(path, now)
+			continue
+		}
+
+		dated, err := f.date(file, source, directory)
+
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+		}
+
+		if err != nil || !dated {
+			queue.forget(path)
+			continue
+		}
+
+		if file.age <= time.Duration(directory.Age) {
+			queue.schedule(path, expiresAt(file, directory))
+			continue
+		}
+
+		f.removeFile(fs, file, directory.Action, directory, result)
+		removed = append(removed, file)
+	}
+
+	f.archivePending(fs, directory, result)
+
+	for _, file := range removed {
+		if file.deleted {
+			queue.forget(file.path)
+		} else {
+			queue.retry(file.path, now)
+		}
+	}
+}
+
+// statFile returns the details of a given file as a listing would
+func (f FileHandler) statFile(fs fs.FileSystem, path string) (*File, error) {
+	info, err := fs.Stat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		createdAt: info.ModTime().Unix(),
+		age:       f.clock.CalculateAge(info.ModTime().Unix()),
+		name:      filepath.Base(path),
+		path:      path,
+		size:      info.Size(),
+		isDir:     info.IsDir(),
+		info:      info,
+	}, nil
+}
+
+// expiresAt returns the time a dated file grows older than the directory
+// age. Ages are compared in whole seconds and must exceed the threshold.
+func expiresAt(file *File, directory config.WatchedDirectory) time.Time {
+	age := time.Duration(directory.Age).Truncate(time.Second) + time.Second
+
+	return time.Unix(file.createdAt, 0).Add(age)
+}
+
+// withinDepth tells whether a path of the watched directory would be
+// listed by a pass over it, given its recursive and maxDepth settings
+func withinDepth(directory config.WatchedDirectory, path string) bool {
+	relative, err := filepath.Rel(directory.Path, path)
+
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	depth := strings.Count(relative, string(filepath.Separator)) + 1
+
+	if !directory.Recursive {
+		return depth == 1
+	}
+
+	return directory.MaxDepth <= 0 || depth <= directory.MaxDepth
+}
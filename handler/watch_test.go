@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"context"
+	"fileman/clock"
+	"fileman/config"
+	filesystem "fileman/fs"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// eventFS is the real filesystem with its events replaced by the ones
+// sent by a test
+type eventFS struct {
+	filesystem.FS
+	events chan filesystem.Event
+}
+
+func (e eventFS) Watch(ctx context.Context, path string, recursive bool) (<-chan filesystem.Event, error) {
+	return e.events, nil
+}
+
+func writeFileAt(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.Nil(t, os.WriteFile(path, []byte(path), 0o644))
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+}
+
+// failingFS is an eventFS failing to delete files a number of times
+type failingFS struct {
+	eventFS
+	failures *atomic.Int32
+}
+
+func (f failingFS) DeleteFile(path string) error {
+	if f.failures.Add(-1) >= 0 {
+		return os.ErrPermission
+	}
+
+	return f.eventFS.DeleteFile(path)
+}
+
+// runNow is a guard letting every task run
+func runNow(task func()) bool {
+	task()
+	return true
+}
+
+// startWatch runs Watch until the test ends, returning the reported results
+func startWatch(t *testing.T, fakeClock *clock.FakeClock, fileSystem filesystem.FileSystem, guard func(task func()) bool, directory config.WatchedDirectory) <-chan Result {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan Result, 10)
+	done := make(chan struct{})
+
+	go func() {
+		New(fakeClock).Watch(ctx, fileSystem, directory, guard, func(result Result) {
+			reports <- result
+		})
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return reports
+}
+
+func assertNoReport(t *testing.T, reports <-chan Result) {
+	t.Helper()
+
+	select {
+	case result := <-reports:
+		t.Fatalf("unexpected report %+v", result)
+	default:
+	}
+}
+
+func TestWatchRemovesFilesAsTheyExpire(t *testing.T) {
+	watched := t.TempDir()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	fileSystem := eventFS{events: make(chan filesystem.Event)}
+
+	writeFileAt(t, filepath.Join(watched, "old.log"), now.Add(-48*time.Hour))
+	writeFileAt(t, filepath.Join(watched, "new.log"), now)
+
+	reports := startWatch(t, fakeClock, fileSystem, runNow, config.WatchedDirectory{Path: watched, Age: config.Days(1)})
+
+	result := <-reports
+	assert.Equal(t, []string{filepath.Join(watched, "old.log")}, result.Deleted)
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(24 * time.Hour)
+	assertNoReport(t, reports)
+	assert.FileExists(t, filepath.Join(watched, "new.log"))
+
+	fakeClock.Advance(time.Second)
+	result = <-reports
+	assert.Equal(t, []string{filepath.Join(watched, "new.log")}, result.Deleted)
+	assert.NoFileExists(t, filepath.Join(watched, "new.log"))
+
+	created := filepath.Join(watched, "created.log")
+	writeFileAt(t, created, fakeClock.Now())
+	fileSystem.events <- filesystem.Event{Path: created}
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(24*time.Hour + time.Second)
+	result = <-reports
+	assert.Equal(t, []string{created}, result.Deleted)
+	assert.Equal(t, 0, len(result.Errors))
+}
+
+func TestWatchDatesFilesAgainWhenTheyExpire(t *testing.T) {
+	watched := t.TempDir()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	fileSystem := eventFS{events: make(chan filesystem.Event)}
+	touched := filepath.Join(watched, "touched.log")
+
+	reports := startWatch(t, fakeClock, fileSystem, runNow, config.WatchedDirectory{Path: watched, Age: config.Days(1)})
+
+	writeFileAt(t, touched, now)
+	fileSystem.events <- filesystem.Event{Path: touched}
+	fakeClock.BlockUntil(1)
+
+	modTime := now.Add(12 * time.Hour)
+	assert.Nil(t, os.Chtimes(touched, modTime, modTime))
+
+	fakeClock.Advance(24*time.Hour + time.Second)
+	fakeClock.BlockUntil(1)
+	assertNoReport(t, reports)
+	assert.FileExists(t, touched)
+
+	fakeClock.Advance(12 * time.Hour)
+	result := <-reports
+	assert.Equal(t, []string{touched}, result.Deleted)
+}
+
+func TestWatchIgnoresRemovedAndUnselectedFiles(t *testing.T) {
+	watched := t.TempDir()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	fileSystem := eventFS{events: make(chan filesystem.Event)}
+	removed := filepath.Join(watched, "removed.log")
+	excluded := filepath.Join(watched, "keep.txt")
+	nested := filepath.Join(watched, "sub", "nested.log")
+
+	reports := startWatch(t, fakeClock, fileSystem, runNow, config.WatchedDirectory{
+		Path:    watched,
+		Age:     config.Days(1),
+		Include: []string{"**/*.log"},
+	})
+
+	writeFileAt(t, removed, now)
+	writeFileAt(t, excluded, now)
+	writeFileAt(t, nested, now)
+
+	fileSystem.events <- filesystem.Event{Path: removed}
+	fileSystem.events <- filesystem.Event{Path: removed, Removed: true}
+	fileSystem.events <- filesystem.Event{Path: excluded}
+	fileSystem.events <- filesystem.Event{Path: nested}
+	fileSystem.events <- filesystem.Event{Path: filepath.Join(watched, "missing.log")}
+
+	fakeClock.Advance(48 * time.Hour)
+	fileSystem.events <- filesystem.Event{Path: excluded}
+
+	assertNoReport(t, reports)
+	assert.FileExists(t, removed)
+	assert.FileExists(t, excluded)
+	assert.FileExists(t, nested)
+}
+
+func TestWatchPutsOffExpiriesUntilMaintenanceWindow(t *testing.T) {
+	watched := t.TempDir()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	fileSystem := eventFS{events: make(chan filesystem.Event)}
+	old := filepath.Join(watched, "old.log")
+
+	writeFileAt(t, old, now.Add(-48*time.Hour))
+
+	reports := startWatch(t, fakeClock, fileSystem, runNow, config.WatchedDirectory{
+		Path:     watched,
+		Age:      config.Days(1),
+		Timezone: "UTC",
+		Windows:  []config.Window{{Start: time.Hour, End: 5 * time.Hour}},
+	})
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(12*time.Hour + 59*time.Minute)
+	assertNoReport(t, reports)
+	assert.FileExists(t, old)
+
+	fakeClock.Advance(time.Minute)
+	result := <-reports
+	assert.Equal(t, []string{old}, result.Deleted)
+}
+
+func TestWatchRetriesRemovalsPutOffByTheGuard(t *testing.T) {
+	watched := t.TempDir()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	fileSystem := eventFS{events: make(chan filesystem.Event)}
+	old := filepath.Join(watched, "old.log")
+	refusals := atomic.Int32{}
+
+	writeFileAt(t, old, now.Add(-48*time.Hour))
+
+	guard := func(task func()) bool {
+		if refusals.Add(1) == 1 {
+			return false
+		}
+
+		return runNow(task)
+	}
+
+	reports := startWatch(t, fakeClock, fileSystem, guard, config.WatchedDirectory{Path: watched, Age: config.Days(1)})
+
+	fakeClock.BlockUntil(1)
+	assertNoReport(t, reports)
+	assert.FileExists(t, old)
+
+	fakeClock.Advance(guardRetryDelay)
+	result := <-reports
+	assert.Equal(t, []string{old}, result.Deleted)
+	assert.Equal(t, int32(2), refusals.Load())
+}
+
+func TestWatchRetriesFailedRemovals(t *testing.T) {
+	watched := t.TempDir()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	failures := &atomic.Int32{}
+	failures.Store(2)
+	fileSystem := failingFS{eventFS{events: make(chan filesystem.Event)}, failures}
+	old := filepath.Join(watched, "old.log")
+
+	writeFileAt(t, old, now.Add(-48*time.Hour))
+
+	reports := startWatch(t, fakeClock, fileSystem, runNow, config.WatchedDirectory{Path: watched, Age: config.Days(1)})
+
+	result := <-reports
+	assert.Equal(t, 1, len(result.Errors))
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(minRetryDelay)
+	result = <-reports
+	assert.Equal(t, 1, len(result.Errors))
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(2*minRetryDelay - time.Second)
+	assertNoReport(t, reports)
+
+	fakeClock.Advance(time.Second)
+	result = <-reports
+	assert.Equal(t, []string{old}, result.Deleted)
+	assert.Equal(t, 0, len(result.Errors))
+}
+
+func TestExpiryQueue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	queue := newExpiryQueue()
+
+	queue.schedule("a", now.Add(3*time.Hour))
+	queue.schedule("b", now.Add(time.Hour))
+	queue.schedule("dir/c", now.Add(2*time.Hour))
+	queue.schedule("dir/d", now.Add(2*time.Hour))
+	queue.schedule("directory", now.Add(2*time.Hour))
+	queue.schedule("a", now.Add(30*time.Minute))
+
+	assert.Equal(t, "a", queue.next().path)
+
+	queue.cancel("dir")
+
+	assert.Equal(t, []string{"a", "b"}, queue.due(now.Add(time.Hour)))
+	assert.Equal(t, []string{"directory"}, queue.due(now.Add(4*time.Hour)))
+	assert.Nil(t, queue.next())
+
+	for _, delay := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second} {
+		queue.retry("a", now)
+		assert.Equal(t, now.Add(delay), queue.next().deadline)
+	}
+
+	queue.forget("a")
+	queue.retry("a", now)
+	assert.Equal(t, now.Add(minRetryDelay), queue.next().deadline)
+
+	for range 20 {
+		queue.retry("a", now)
+	}
+
+	assert.Equal(t, now.Add(maxRetryDelay), queue.next().deadline)
+}
+
+func TestWithinDepth(t *testing.T) {
+	flat := config.WatchedDirectory{Path: "/data"}
+	recursive := config.WatchedDirectory{Path: "/data", Recursive: true}
+	shallow := config.WatchedDirectory{Path: "/data", Recursive: true, MaxDepth: 2}
+
+	assert.True(t, withinDepth(flat, "/data/a.log"))
+	assert.False(t, withinDepth(flat, "/data/sub/a.log"))
+	assert.False(t, withinDepth(flat, "/data"))
+	assert.False(t, withinDepth(flat, "/other/a.log"))
+	assert.True(t, withinDepth(recursive, "/data/sub/deeper/a.log"))
+	assert.True(t, withinDepth(shallow, "/data/sub/a.log"))
+	assert.False(t, withinDepth(shallow, "/data/sub/deeper/a.log"))
+}
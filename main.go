@@ -1,25 +1,27 @@
 package main
 
 import (
+	"context"
 	"fileman/clock"
 	"fileman/config"
 	"fileman/fs"
 	"fileman/handler"
+	"fileman/runner"
 	"github.com/go-co-op/gocron/v2"
 	"os"
-	"runtime"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	realClock := clock.RealClock{}
-	fileHandler := handler.New(realClock)
 	fileSystem := fs.FS{}
 
 	logger := gocron.NewLogger(gocron.LogLevelInfo)
-	scheduler, err := gocron.NewScheduler(gocron.WithLogger(logger))
-	if err != nil {
-		panic(err)
-	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	configFile, configExists := os.LookupEnv("CONFIG_PATH")
 	if !configExists {
@@ -28,53 +30,102 @@ func main() {
 
 	configObject, configError := config.New(configFile).Load()
 	if configError != nil {
-		panic(configError)
+		logErrors(logger, "Invalid configuration", configFile, configError)
+		os.Exit(1)
 	}
 
-	if configObject.Cron == "" {
-		panic("Cron expression not set in configObject")
+	gracePeriod := time.Duration(configObject.GracePeriod) * time.Second
+	if configObject.GracePeriod <= 0 {
+		gracePeriod = 30 * time.Second
 	}
 
-	errs := make([]error, 0)
-	jobs := make([]gocron.Job, 0)
-
-	for _, directory := range configObject.WatchedDirectories {
-		job, e := scheduler.NewJob(
-			gocron.CronJob(configObject.Cron, false),
-			gocron.NewTask(func() {
-				deleted, errs := fileHandler.DeleteOldFiles(fileSystem, directory.Path, directory.Age)
-				for _, d := range deleted {
-					logger.Info("Deleted file", d)
-				}
-
-				for _, e := range errs {
-					logger.Error("Error deleting file", e.Error())
-				}
-
-				if len(deleted) == 0 && len(errs) == 0 {
-					logger.Info("No files to delete in path", directory.Path)
-				}
-			}),
-			gocron.WithName("PathCleaner-"+directory.Path),
-		)
-
-		if e != nil {
-			errs = append(errs, e)
-			continue
-		}
+	scheduler, err := gocron.NewScheduler(gocron.WithLogger(logger), gocron.WithStopTimeout(gracePeriod))
+	if err != nil {
+		panic(err)
+	}
+
+	var state *runner.State
 
-		jobs = append(jobs, job)
+	if configObject.State != "" {
+		if state, err = runner.LoadState(fileSystem, configObject.State); err != nil {
+			logErrors(logger, "Invalid state file", configObject.State, err)
+			os.Exit(1)
+		}
 	}
 
-	for _, e := range errs {
-		logger.Error("Error scheduling job", e.Error())
+	service := newService(ctx, logger, scheduler, realClock, fileSystem, state)
+
+	if err := service.apply(configObject, true); err != nil {
+		logErrors(logger, "Error scheduling jobs", configFile, err)
+		os.Exit(1)
 	}
 
-	for _, job := range jobs {
+	for _, job := range scheduler.Jobs() {
 		logger.Info("Scheduled Job", "Name", job.Name(), "ID", job.ID())
 	}
 
 	scheduler.Start()
 
-	runtime.Goexit()
+	reload(ctx, logger, service, fileSystem, configFile, configObject)
+	stop()
+
+	os.Exit(service.shutdown(gracePeriod))
+}
+
+// logErrors logs each of the errors joined in an error on its own line
+func logErrors(logger gocron.Logger, message string, path string, err error) {
+	errs := []error{err}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+
+	for _, e := range errs {
+		logger.Error(message, path, "Error", e.Error())
+	}
+}
+
+// logResult logs the outcome of a cleanup pass over a watched directory,
+// adding it up to the summary
+func logResult(logger gocron.Logger, summary *handler.Summary, path string, result handler.Result) {
+	summary.Add(result)
+
+	outcomes := []struct {
+		message       string
+		dryRunMessage string
+		paths         []string
+	}{
+		{"Compressed file", "[dry run] Would compress file", result.Compressed},
+		{"Deleted file", "[dry run] Would delete file", result.Deleted},
+		{"Moved file to trash", "[dry run] Would move file to trash", result.Trashed},
+		{"Purged file from trash", "[dry run] Would purge file from trash", result.Purged},
+		{"Archived file", "[dry run] Would archive file", result.Archived},
+		{"Removed empty directory", "[dry run] Would remove empty directory", result.RemovedDirs},
+		{"Skipped file", "[dry run] Skipped file", result.Skipped},
+	}
+
+	for _, outcome := range outcomes {
+		message := outcome.message
+		if result.DryRun {
+			message = outcome.dryRunMessage
+		}
+
+		for _, p := range outcome.paths {
+			logger.Info(message, p)
+		}
+	}
+
+	if result.Archive != "" && result.DryRun {
+		logger.Info("[dry run] Would write archive", result.Archive)
+	} else if result.Archive != "" {
+		logger.Info("Archive written", result.Archive)
+	}
+
+	for _, e := range result.Errors {
+		logger.Error("Error deleting file", e.Error())
+	}
+
+	if result.IsEmpty() {
+		logger.Info("No files to delete in path", path)
+	}
 }
@@ -11,6 +11,7 @@ package mocks
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -39,11 +40,25 @@ func (m *MockClock) EXPECT() *MockClockMockRecorder {
 	return m.recorder
 }
 
+// After mocks base method.
+func (m *MockClock) After(d time.Duration) <-chan time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "After", d)
+	ret0, _ := ret[0].(<-chan time.Time)
+	return ret0
+}
+
+// After indicates an expected call of After.
+func (mr *MockClockMockRecorder) After(d any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "After", reflect.TypeOf((*MockClock)(nil).After), d)
+}
+
 // CalculateAge mocks base method.
-func (m *MockClock) CalculateAge(reference int64) float64 {
+func (m *MockClock) CalculateAge(reference int64) time.Duration {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CalculateAge", reference)
-	ret0, _ := ret[0].(float64)
+	ret0, _ := ret[0].(time.Duration)
 	return ret0
 }
 
@@ -53,6 +68,20 @@ func (mr *MockClockMockRecorder) CalculateAge(reference any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CalculateAge", reflect.TypeOf((*MockClock)(nil).CalculateAge), reference)
 }
 
+// Now mocks base method.
+func (m *MockClock) Now() time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Now")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// Now indicates an expected call of Now.
+func (mr *MockClockMockRecorder) Now() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Now", reflect.TypeOf((*MockClock)(nil).Now))
+}
+
 // Unix mocks base method.
 func (m *MockClock) Unix() int64 {
 	m.ctrl.T.Helper()
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fileman/config"
+	"fileman/fs"
+	"github.com/go-co-op/gocron/v2"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// reloadDelay is how long the configuration files have to stay unchanged
+// before they are loaded again
+const reloadDelay = 500 * time.Millisecond
+
+// applier applies a configuration reloaded, as the service does
+type applier interface {
+	apply(configObject config.Config, startup bool) error
+}
+
+// reload applies the configuration again whenever one of its files
+// changes or fileman receives SIGHUP, until the context is done. An
+// invalid configuration is logged and ignored, the current one being kept.
+func reload(ctx context.Context, logger gocron.Logger, service applier, fileSystem fs.FileSystem, configFile string, current config.Config) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	configHandler := config.New(configFile)
+	watches := &configWatches{
+		ctx:           ctx,
+		logger:        logger,
+		fileSystem:    fileSystem,
+		configHandler: configHandler,
+		changes:       make(chan struct{}, 1),
+		cancels:       make(map[string]context.CancelFunc),
+	}
+	watches.sync(current)
+
+	var settled <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-watches.changes:
+			// Changes come in bursts while the files are written, so the
+			// configuration is only loaded once they settle
+			settled = time.After(reloadDelay)
+			continue
+		case <-hangups:
+			logger.Info("Received SIGHUP, reloading configuration", configFile)
+		case <-settled:
+		}
+
+		settled = nil
+		next, err := configHandler.Load()
+
+		if err != nil {
+			logErrors(logger, "Invalid configuration, keeping the current one", configFile, err)
+			continue
+		}
+
+		watches.sync(next)
+
+		if reflect.DeepEqual(next, current) {
+			continue
+		}
+
+		if next.State != current.State || next.GracePeriod != current.GracePeriod {
+			logger.Info("Changes to state and gracePeriod take effect on restart", configFile)
+		}
+
+		if err := service.apply(next, false); err != nil {
+			logErrors(logger, "Error applying configuration", configFile, err)
+		}
+
+		current = next
+		logger.Info("Configuration reloaded", configFile)
+	}
+}
+
+// configWatches watches the directories the files of the configuration
+// are read from, rather than the files themselves, as editors and
+// Kubernetes replace them rather than write to them. The changes to other
+// files, such as logs or the state file, are left out.
+type configWatches struct {
+	ctx           context.Context
+	logger        gocron.Logger
+	fileSystem    fs.FileSystem
+	configHandler *config.ConfigHandler
+	changes       chan struct{}
+	cancels       map[string]context.CancelFunc
+
+	// current is the configuration last synced, whose include globs tell
+	// which files it is read from
+	current atomic.Pointer[config.Config]
+}
+
+// sync watches the directories the files of a configuration are read
+// from, and stops watching the others. A missing directory is left out
+// until the configuration is reloaded once it exists, such as on SIGHUP.
+func (w *configWatches) sync(configObject config.Config) {
+	w.current.Store(&configObject)
+	kept := make(map[string]bool)
+
+	for _, dir := range w.configHandler.Dirs(configObject) {
+		kept[dir] = true
+
+		if _, watched := w.cancels[dir]; watched {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(w.ctx)
+		events, err := w.fileSystem.Watch(ctx, dir, false)
+
+		if err != nil {
+			cancel()
+
+			if !errors.Is(err, os.ErrNotExist) {
+				w.logger.Error("Error watching configuration, reload it with SIGHUP", dir, "Error", err.Error())
+			}
+
+			continue
+		}
+
+		w.cancels[dir] = cancel
+
+		go func() {
+			for event := range events {
+				if event.Err == nil && !w.configHandler.IsSource(*w.current.Load(), event.Path) {
+					continue
+				}
+
+				select {
+				case w.changes <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	for dir, cancel := range w.cancels {
+		if !kept[dir] {
+			cancel()
+			delete(w.cancels, dir)
+		}
+	}
+}
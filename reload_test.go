@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fileman/config"
+	"fileman/fs"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// recordingApplier records the configurations applied
+type recordingApplier struct {
+	applied chan config.Config
+}
+
+func (r recordingApplier) apply(configObject config.Config, startup bool) error {
+	r.applied <- configObject
+	return nil
+}
+
+// startReload runs reload until the test ends, returning the
+// configurations it applies
+func startReload(t *testing.T, configFile string) <-chan config.Config {
+	t.Helper()
+
+	current, err := config.New(configFile).Load()
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	applied := make(chan config.Config, 10)
+	done := make(chan struct{})
+
+	go func() {
+		reload(ctx, gocron.NewLogger(gocron.LogLevelError), recordingApplier{applied}, fs.FS{}, configFile, current)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return applied
+}
+
+func assertNothingApplied(t *testing.T, applied <-chan config.Config, wait time.Duration) {
+	t.Helper()
+
+	select {
+	case configObject := <-applied:
+		t.Fatalf("unexpected configuration applied %+v", configObject)
+	case <-time.After(wait):
+	}
+}
+
+func nextApplied(t *testing.T, applied <-chan config.Config, wait time.Duration) config.Config {
+	t.Helper()
+
+	select {
+	case configObject := <-applied:
+		return configObject
+	case <-time.After(wait):
+		t.Fatal("configuration not reloaded")
+		return config.Config{}
+	}
+}
+
+func TestReloadAppliesChangesOnceSettled(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(configFile, []byte(`{"cron": "0 * * * *", "watchedDirectories": [{"path": "/srv/a"}]}`), 0o644))
+
+	applied := startReload(t, configFile)
+
+	// The files are watched once reload starts, so the burst of writes is
+	// repeated until one is seen
+	var next config.Config
+	var lastWrite time.Time
+
+	for next.Cron == "" {
+		for _, cron := range []string{"1 * * * *", "2 * * * *", "3 * * * *"} {
+			content := `{"cron": "` + cron + `", "watchedDirectories": [{"path": "/srv/a"}]}`
+			assert.Nil(t, os.WriteFile(configFile, []byte(content), 0o644))
+			lastWrite = time.Now()
+			time.Sleep(reloadDelay / 10)
+		}
+
+		select {
+		case next = <-applied:
+			assert.GreaterOrEqual(t, time.Since(lastWrite), reloadDelay)
+		case <-time.After(3 * reloadDelay):
+		}
+	}
+
+	assert.Equal(t, "3 * * * *", next.Cron)
+	assertNothingApplied(t, applied, 2*reloadDelay)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(filepath.Dir(configFile), "fileman.log"), []byte("unrelated"), 0o644))
+	assert.Nil(t, os.WriteFile(configFile, []byte(`{"cron": "4 * * * *", "watchedDirectories": [{"path": "relative"}]}`), 0o644))
+	assertNothingApplied(t, applied, 3*reloadDelay)
+
+	t.Setenv("FILEMAN_TEST_CRON", "5 * * * *")
+	assert.Nil(t, os.WriteFile(configFile, []byte(`{"cron": "${FILEMAN_TEST_CRON}", "watchedDirectories": [{"path": "/srv/a"}]}`), 0o644))
+	assert.Equal(t, "5 * * * *", nextApplied(t, applied, 3*reloadDelay).Cron)
+}
+
+func TestConfigWatchesSync(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watches := &configWatches{
+		ctx:           ctx,
+		logger:        gocron.NewLogger(gocron.LogLevelError),
+		fileSystem:    fs.FS{},
+		configHandler: config.New(filepath.Join(root, "config.json")),
+		changes:       make(chan struct{}, 1),
+		cancels:       make(map[string]context.CancelFunc),
+	}
+
+	watched := func() []string {
+		dirs := make([]string, 0, len(watches.cancels))
+		for dir := range watches.cancels {
+			dirs = append(dirs, dir)
+		}
+
+		sort.Strings(dirs)
+
+		return dirs
+	}
+
+	teams := filepath.Join(root, "teams")
+	assert.Nil(t, os.Mkdir(teams, 0o755))
+
+	watches.sync(config.Config{Include: []string{"teams/*.yaml"}})
+	assert.Equal(t, []string{root, teams}, watched())
+
+	assert.Nil(t, os.WriteFile(filepath.Join(teams, "notes.txt"), []byte("unrelated"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(teams, "b.json"), []byte("{}"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "state.json"), []byte("{}"), 0o644))
+
+	select {
+	case <-watches.changes:
+		t.Fatal("change to a file left out seen")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Nil(t, os.WriteFile(filepath.Join(teams, "a.yaml"), []byte("watchedDirectories: []\n"), 0o644))
+
+	select {
+	case <-watches.changes:
+	case <-time.After(time.Second):
+		t.Fatal("change to a fragment not seen")
+	}
+
+	watches.sync(config.Config{})
+	assert.Equal(t, []string{root}, watched())
+}
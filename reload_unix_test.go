@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestReloadAppliesChangesOnSIGHUP(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("FILEMAN_TEST_CRON", "5 * * * *")
+	assert.Nil(t, os.WriteFile(configFile, []byte(`{"cron": "${FILEMAN_TEST_CRON}", "watchedDirectories": [{"path": "/srv/a"}]}`), 0o644))
+
+	applied := startReload(t, configFile)
+
+	// Environment variables aren't watched, so their changes are only
+	// picked up on SIGHUP, sent once reload had time to listen for it
+	t.Setenv("FILEMAN_TEST_CRON", "6 * * * *")
+	assertNothingApplied(t, applied, 2*reloadDelay)
+	assert.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	assert.Equal(t, "6 * * * *", nextApplied(t, applied, reloadDelay).Cron)
+}
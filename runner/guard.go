@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// Outcome tells what became of a run submitted to a Guard
+type Outcome int
+
+const (
+	// Ran means the run was carried out
+	Ran Outcome = iota
+	// Skipped means the run was dropped as another one was going
+	Skipped
+	// Queued means the run will start once the one going ends
+	Queued
+	// Locked means the run was dropped as it couldn't take the lock,
+	// another replica holding it
+	Locked
+	// Closed means the run was dropped as fileman is shutting down
+	Closed
+)
+
+// Guard keeps the runs over a watched directory from overlapping. A run
+// submitted while another one is going is skipped with the "skip" policy,
+// the default, put off until the one going ends with "queue", a single run
+// being queued at a time, or waits for its turn with "wait". With a locker,
+// the runs also take the lock of the key in turn, so that replicas sharing
+// the directory don't clean it at the same time.
+type Guard struct {
+	policy  string
+	locker  gocron.Locker
+	key     string
+	mutex   sync.Mutex
+	idle    *sync.Cond
+	running bool
+	closed  bool
+	next    func()
+}
+
+func NewGuard(policy string, locker gocron.Locker, key string) *Guard {
+	guard := &Guard{policy: policy, locker: locker, key: key}
+	guard.idle = sync.NewCond(&guard.mutex)
+
+	return guard
+}
+
+// Run runs the task unless another run is going, then runs the one queued
+// meanwhile if any, before returning. The error tells why the lock couldn't
+// be taken, or released.
+func (g *Guard) Run(task func()) (Outcome, error) {
+	return g.run(task, g.policy)
+}
+
+// RunNow runs the task unless another run is going, whatever the policy,
+// so that the task never runs after RunNow returns
+func (g *Guard) RunNow(task func()) (Outcome, error) {
+	return g.run(task, "skip")
+}
+
+func (g *Guard) run(task func(), policy string) (Outcome, error) {
+	g.mutex.Lock()
+
+	if g.closed {
+		g.mutex.Unlock()
+		return Closed, nil
+	}
+
+	if g.running {
+		switch policy {
+		case "wait":
+			for g.running {
+				g.idle.Wait()
+			}
+
+			if g.closed {
+				g.mutex.Unlock()
+				return Closed, nil
+			}
+		case "queue":
+			defer g.mutex.Unlock()
+
+			if g.next != nil {
+				return Skipped, nil
+			}
+
+			g.next = task
+			return Queued, nil
+		default:
+			g.mutex.Unlock()
+			return Skipped, nil
+		}
+	}
+
+	g.running = true
+	g.mutex.Unlock()
+
+	var lock gocron.Lock
+
+	if g.locker != nil {
+		var err error
+
+		if lock, err = g.locker.Lock(context.Background(), g.key); err != nil {
+			g.mutex.Lock()
+			g.running = false
+			g.next = nil
+			g.idle.Broadcast()
+			g.mutex.Unlock()
+
+			return Locked, err
+		}
+	}
+
+	for {
+		task()
+
+		g.mutex.Lock()
+
+		if g.next == nil {
+			var err error
+
+			if lock != nil {
+				err = lock.Unlock(context.Background())
+			}
+
+			g.running = false
+			g.idle.Broadcast()
+			g.mutex.Unlock()
+
+			return Ran, err
+		}
+
+		task, g.next = g.next, nil
+		g.mutex.Unlock()
+	}
+}
+
+// Close refuses the runs submitted from then on, along with the one queued
+// and the ones waiting, letting the run going finish
+func (g *Guard) Close() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.closed = true
+	g.next = nil
+	g.idle.Broadcast()
+}
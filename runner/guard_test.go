@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startBlockingRun submits a run to the guard that lasts until release is
+// closed, returning once it has started
+func startBlockingRun(guard *Guard, release chan struct{}, outcome chan Outcome) {
+	started := make(chan struct{})
+
+	go func() {
+		ran, _ := guard.Run(func() {
+			close(started)
+			<-release
+		})
+		outcome <- ran
+	}()
+
+	<-started
+}
+
+// assertOutcome checks the outcome of a run, which mustn't have failed
+func assertOutcome(t *testing.T, expected Outcome) func(Outcome, error) {
+	t.Helper()
+
+	return func(outcome Outcome, err error) {
+		t.Helper()
+
+		assert.Equal(t, expected, outcome)
+		assert.Nil(t, err)
+	}
+}
+
+func TestGuardSkipsOverlappingRuns(t *testing.T) {
+	guard := NewGuard("", nil, "")
+	release := make(chan struct{})
+	outcome := make(chan Outcome, 1)
+	runs := atomic.Int32{}
+
+	startBlockingRun(guard, release, outcome)
+
+	assertOutcome(t, Skipped)(guard.Run(func() { runs.Add(1) }))
+	assertOutcome(t, Skipped)(guard.Run(func() { runs.Add(1) }))
+
+	close(release)
+	assert.Equal(t, Ran, <-outcome)
+	assert.Equal(t, int32(0), runs.Load())
+
+	assertOutcome(t, Ran)(guard.Run(func() { runs.Add(1) }))
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+func TestGuardQueuesOneRun(t *testing.T) {
+	guard := NewGuard("queue", nil, "")
+	release := make(chan struct{})
+	outcome := make(chan Outcome, 1)
+	order := make([]string, 0)
+
+	startBlockingRun(guard, release, outcome)
+
+	assertOutcome(t, Queued)(guard.Run(func() { order = append(order, "queued") }))
+	assertOutcome(t, Skipped)(guard.Run(func() { order = append(order, "skipped") }))
+
+	close(release)
+	assert.Equal(t, Ran, <-outcome)
+	assert.Equal(t, []string{"queued"}, order)
+
+	assertOutcome(t, Ran)(guard.Run(func() { order = append(order, "next") }))
+	assert.Equal(t, []string{"queued", "next"}, order)
+}
+
+func TestGuardWaitsForTheRunGoing(t *testing.T) {
+	guard := NewGuard("wait", nil, "")
+	release := make(chan struct{})
+	outcome := make(chan Outcome, 1)
+	running := atomic.Int32{}
+	overlapped := atomic.Bool{}
+	waiters := sync.WaitGroup{}
+
+	startBlockingRun(guard, release, outcome)
+
+	for range 3 {
+		waiters.Add(1)
+
+		go func() {
+			defer waiters.Done()
+
+			assertOutcome(t, Ran)(guard.Run(func() {
+				if running.Add(1) > 1 {
+					overlapped.Store(true)
+				}
+
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+			}))
+		}()
+	}
+
+	close(release)
+	waiters.Wait()
+
+	assert.Equal(t, Ran, <-outcome)
+	assert.False(t, overlapped.Load())
+}
+
+func TestGuardCloseRefusesNextRuns(t *testing.T) {
+	guard := NewGuard("queue", nil, "")
+	release := make(chan struct{})
+	outcome := make(chan Outcome, 1)
+	runs := atomic.Int32{}
+
+	startBlockingRun(guard, release, outcome)
+
+	assertOutcome(t, Queued)(guard.Run(func() { runs.Add(1) }))
+
+	guard.Close()
+
+	assertOutcome(t, Closed)(guard.Run(func() { runs.Add(1) }))
+
+	close(release)
+	assert.Equal(t, Ran, <-outcome)
+	assert.Equal(t, int32(0), runs.Load())
+}
+
+func TestGuardCloseReleasesWaitingRuns(t *testing.T) {
+	guard := NewGuard("wait", nil, "")
+	release := make(chan struct{})
+	outcome := make(chan Outcome, 1)
+	waiting := make(chan Outcome, 1)
+
+	startBlockingRun(guard, release, outcome)
+
+	go func() {
+		ran, _ := guard.Run(func() {})
+		waiting <- ran
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	guard.Close()
+	close(release)
+
+	assert.Equal(t, Ran, <-outcome)
+	assert.Equal(t, Closed, <-waiting)
+}
+
+func TestGuardRunNowSkipsWhateverThePolicy(t *testing.T) {
+	for _, policy := range []string{"skip", "queue", "wait"} {
+		guard := NewGuard(policy, nil, "")
+		release := make(chan struct{})
+		outcome := make(chan Outcome, 1)
+		runs := atomic.Int32{}
+
+		startBlockingRun(guard, release, outcome)
+
+		assertOutcome(t, Skipped)(guard.RunNow(func() { runs.Add(1) }))
+
+		close(release)
+		assert.Equal(t, Ran, <-outcome, policy)
+		assert.Equal(t, int32(0), runs.Load(), policy)
+
+		assertOutcome(t, Ran)(guard.RunNow(func() { runs.Add(1) }))
+		assert.Equal(t, int32(1), runs.Load(), policy)
+	}
+}
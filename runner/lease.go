@@ -0,0 +1,309 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fileman/clock"
+	"fileman/fs"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// ErrLeaseHeld is returned when another replica holds the lease
+var ErrLeaseHeld = errors.New("lease held by another replica")
+
+// settleTime is the time a lease is kept after being acquired, even when
+// released earlier, so that replicas whose clock is slightly behind don't
+// run the same tick again once the job is done
+const settleTime = 5 * time.Second
+
+var unsafeLeaseChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// lease is the content of a lease file
+type lease struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// LeaseLocker is a gocron.Locker letting a single replica of fileman at a
+// time hold the lock of a key, such as the path of a watched directory.
+// The lock is a lease file created exclusively in a directory shared by
+// the replicas, which its holder renews while running and removes once
+// done. A lease left behind by a crashed replica expires after the TTL
+// and is then broken by the next replica needing it.
+type LeaseLocker struct {
+	fs     fs.FileSystem
+	clock  clock.Clock
+	dir    string
+	owner  string
+	ttl    time.Duration
+	settle time.Duration
+	mutex  sync.Mutex
+	held   map[string]bool
+}
+
+func NewLeaseLocker(fs fs.FileSystem, clock clock.Clock, dir string, ttl time.Duration) *LeaseLocker {
+	return &LeaseLocker{
+		fs:     fs,
+		clock:  clock,
+		dir:    dir,
+		owner:  newOwner(),
+		ttl:    ttl,
+		settle: settleTime,
+		held:   make(map[string]bool),
+	}
+}
+
+// leasePath returns the lease file of a key, a hash of the key telling
+// apart the keys whose sanitized forms collide
+func (l *LeaseLocker) leasePath(key string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	name := fmt.Sprintf("%s-%08x.lease", unsafeLeaseChars.ReplaceAllString(key, "_"), hash.Sum32())
+
+	return filepath.Join(l.dir, name)
+}
+
+// newOwner identifies the replica, and the locker within it
+func newOwner() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Lock acquires the lease of a key, breaking it first when it has expired
+func (l *LeaseLocker) Lock(ctx context.Context, key string) (gocron.Lock, error) {
+	path := l.leasePath(key)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held[path] {
+		return nil, fmt.Errorf("%w: %s", ErrLeaseHeld, l.owner)
+	}
+
+	for range 2 {
+		acquiredAt := l.clock.Now()
+		err := l.create(path, acquiredAt)
+
+		if err == nil {
+			return l.hold(path, acquiredAt), nil
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		current, err := l.read(path)
+
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if current.Owner == l.owner && l.write(path, acquiredAt.Add(l.ttl)) == nil {
+			return l.hold(path, acquiredAt), nil
+		}
+
+		if l.clock.Now().Before(current.ExpiresAt) {
+			return nil, fmt.Errorf("%w: %s until %s", ErrLeaseHeld, current.Owner, current.ExpiresAt.Format(time.RFC3339))
+		}
+
+		if err := l.breakExpired(path, current); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrLeaseHeld
+}
+
+// create creates a lease file, failing if it already exists
+func (l *LeaseLocker) create(path string, now time.Time) error {
+	content, err := json.Marshal(lease{Owner: l.owner, ExpiresAt: now.Add(l.ttl)})
+
+	if err != nil {
+		return err
+	}
+
+	writer, err := l.fs.Create(path)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(content)
+
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = l.fs.DeleteFile(path)
+	}
+
+	return err
+}
+
+// read returns the lease of a given file. A file left unreadable by a
+// replica crashing while creating it expires a TTL after it was written.
+func (l *LeaseLocker) read(path string) (lease, error) {
+	content, err := l.fs.ReadFile(path)
+
+	if err != nil {
+		return lease{}, err
+	}
+
+	current := lease{}
+
+	if json.Unmarshal(content, &current) == nil {
+		return current, nil
+	}
+
+	info, err := l.fs.Stat(path)
+
+	if err != nil {
+		return lease{}, err
+	}
+
+	return lease{ExpiresAt: info.ModTime().Add(l.ttl)}, nil
+}
+
+// breakExpired removes an expired lease file. It is moved aside first, and
+// put back if it turns out to have been renewed or taken over meanwhile by
+// another replica.
+func (l *LeaseLocker) breakExpired(path string, expired lease) error {
+	aside := path + "." + l.owner
+
+	if err := l.fs.Rename(path, aside); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	moved, err := l.read(aside)
+
+	if err == nil && moved != expired {
+		if err := l.fs.Rename(aside, path); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("%w: %s until %s", ErrLeaseHeld, moved.Owner, moved.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return l.fs.DeleteFile(aside)
+}
+
+// write replaces a lease file with one of the locker expiring at a given
+// time, unless the lease has been taken over by another replica
+func (l *LeaseLocker) write(path string, expiresAt time.Time) error {
+	current, err := l.read(path)
+
+	if err != nil {
+		return err
+	}
+
+	if current.Owner != l.owner {
+		return fmt.Errorf("%w: taken over by %s", ErrLeaseHeld, current.Owner)
+	}
+
+	content, err := json.Marshal(lease{Owner: l.owner, ExpiresAt: expiresAt})
+
+	if err != nil {
+		return err
+	}
+
+	replacement := path + "." + l.owner
+
+	if err := l.fs.WriteFile(replacement, content); err != nil {
+		return err
+	}
+
+	return l.fs.Rename(replacement, path)
+}
+
+// hold renews a lease acquired at a given time every third of its TTL
+// until it is released. The lease is marked as held meanwhile, so that the
+// locker doesn't hand it out twice, a lease of the locker kept until its
+// settle time being taken back otherwise.
+func (l *LeaseLocker) hold(path string, acquiredAt time.Time) *leaseLock {
+	l.held[path] = true
+
+	lock := &leaseLock{
+		locker:     l,
+		path:       path,
+		acquiredAt: acquiredAt,
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(lock.stopped)
+
+		for {
+			select {
+			case <-lock.stop:
+				return
+			case <-l.clock.After(l.ttl / 3):
+				if l.write(path, l.clock.Now().Add(l.ttl)) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return lock
+}
+
+// leaseLock is a lease held by a LeaseLocker
+type leaseLock struct {
+	locker     *LeaseLocker
+	path       string
+	acquiredAt time.Time
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+// Unlock stops renewing the lease and releases it, unless it has been
+// taken over meanwhile. A lease released before its settle time is kept
+// until then.
+func (l *leaseLock) Unlock(ctx context.Context) error {
+	close(l.stop)
+	<-l.stopped
+
+	locker := l.locker
+
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	delete(locker.held, l.path)
+	settled := l.acquiredAt.Add(locker.settle)
+
+	if locker.clock.Now().Before(settled) {
+		return locker.write(l.path, settled)
+	}
+
+	current, err := locker.read(l.path)
+
+	if err != nil || current.Owner != locker.owner {
+		return err
+	}
+
+	return locker.fs.DeleteFile(l.path)
+}
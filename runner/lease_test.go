@@ -0,0 +1,222 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fileman/clock"
+	"fileman/fs"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseLockerExcludesOtherReplicas(t *testing.T) {
+	dir := t.TempDir()
+	fakeClock := clock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	first := NewLeaseLocker(fs.FS{}, fakeClock, dir, time.Minute)
+	second := NewLeaseLocker(fs.FS{}, fakeClock, dir, time.Minute)
+
+	lock, err := first.Lock(context.Background(), "/files/logs")
+	assert.Nil(t, err)
+
+	_, err = second.Lock(context.Background(), "/files/logs")
+	assert.ErrorIs(t, err, ErrLeaseHeld)
+
+	_, err = first.Lock(context.Background(), "/files/logs")
+	assert.ErrorIs(t, err, ErrLeaseHeld)
+
+	other, err := second.Lock(context.Background(), "/files/tmp")
+	assert.Nil(t, err)
+	assert.Nil(t, other.Unlock(context.Background()))
+
+	fakeClock.Advance(time.Second)
+	assert.Nil(t, lock.Unlock(context.Background()))
+
+	_, err = second.Lock(context.Background(), "/files/logs")
+	assert.ErrorIs(t, err, ErrLeaseHeld, "the lease is kept until its settle time")
+
+	lock, err = first.Lock(context.Background(), "/files/logs")
+	assert.Nil(t, err, "a settling lease is taken back by its replica")
+
+	fakeClock.Advance(settleTime)
+	assert.Nil(t, lock.Unlock(context.Background()))
+	assert.NoFileExists(t, first.leasePath("/files/logs"))
+
+	lock, err = second.Lock(context.Background(), "/files/logs")
+	assert.Nil(t, err)
+	assert.Equal(t, second.owner, readLease(t, second.leasePath("/files/logs")).Owner)
+}
+
+func TestLeaseLockerRenewsHeldLeases(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(start)
+	first := NewLeaseLocker(fs.FS{}, fakeClock, dir, time.Minute)
+	second := NewLeaseLocker(fs.FS{}, fakeClock, dir, time.Minute)
+
+	lock, err := first.Lock(context.Background(), "/files/logs")
+	assert.Nil(t, err)
+
+	for range 6 {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(20 * time.Second)
+	}
+
+	fakeClock.BlockUntil(1)
+	assert.Equal(t, start.Add(3*time.Minute), readLease(t, first.leasePath("/files/logs")).ExpiresAt)
+
+	_, err = second.Lock(context.Background(), "/files/logs")
+	assert.ErrorIs(t, err, ErrLeaseHeld)
+
+	assert.Nil(t, lock.Unlock(context.Background()))
+}
+
+func TestLeaseLockerBreaksExpiredLeases(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	locker := NewLeaseLocker(fs.FS{}, fakeClock, dir, time.Minute)
+	path := locker.leasePath("/files/logs")
+
+	writeLease(t, path, lease{Owner: "crashed", ExpiresAt: now.Add(time.Second)})
+
+	_, err := locker.Lock(context.Background(), "/files/logs")
+	assert.ErrorIs(t, err, ErrLeaseHeld)
+
+	fakeClock.Advance(time.Second)
+
+	lock, err := locker.Lock(context.Background(), "/files/logs")
+	assert.Nil(t, err)
+	assert.Equal(t, locker.owner, readLease(t, path).Owner)
+	assert.Nil(t, lock.Unlock(context.Background()))
+
+	assert.Nil(t, os.WriteFile(path, []byte("{\"own"), 0o644))
+	assert.Nil(t, os.Chtimes(path, now, now))
+
+	_, err = locker.Lock(context.Background(), "/files/logs")
+	assert.ErrorIs(t, err, ErrLeaseHeld, "a truncated lease expires a TTL after it was written")
+
+	fakeClock.Advance(time.Minute)
+
+	_, err = locker.Lock(context.Background(), "/files/logs")
+	assert.Nil(t, err)
+}
+
+func TestLeaseLockerReplicasNeverOverlap(t *testing.T) {
+	dir := t.TempDir()
+	replicas := []*LeaseLocker{
+		NewLeaseLocker(fs.FS{}, clock.RealClock{}, dir, time.Minute),
+		NewLeaseLocker(fs.FS{}, clock.RealClock{}, dir, time.Minute),
+	}
+	holders := atomic.Int32{}
+	overlapped := atomic.Bool{}
+	acquired := atomic.Int32{}
+	workers := sync.WaitGroup{}
+
+	for _, replica := range replicas {
+		replica.settle = 0
+
+		for range 4 {
+			workers.Add(1)
+
+			go func() {
+				defer workers.Done()
+
+				for range 50 {
+					lock, err := replica.Lock(context.Background(), "/files/logs")
+
+					if err != nil {
+						continue
+					}
+
+					if holders.Add(1) > 1 {
+						overlapped.Store(true)
+					}
+
+					acquired.Add(1)
+					time.Sleep(100 * time.Microsecond)
+					holders.Add(-1)
+
+					assert.Nil(t, lock.Unlock(context.Background()))
+				}
+			}()
+		}
+	}
+
+	workers.Wait()
+
+	assert.False(t, overlapped.Load())
+	assert.Positive(t, acquired.Load())
+}
+
+// TestLeaseHelperProcess holds a lease as another process would, until
+// it is killed. It only runs when started by TestLeaseLockerAcrossProcesses.
+func TestLeaseHelperProcess(t *testing.T) {
+	dir := os.Getenv("FILEMAN_LEASE_DIR")
+	if dir == "" {
+		t.Skip("helper process")
+	}
+
+	_, err := NewLeaseLocker(fs.FS{}, clock.RealClock{}, dir, time.Second).Lock(context.Background(), "/files/logs")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("locked")
+	time.Sleep(time.Minute)
+}
+
+func TestLeaseLockerAcrossProcesses(t *testing.T) {
+	dir := t.TempDir()
+	helper := exec.Command(os.Args[0], "-test.run=^TestLeaseHelperProcess$")
+	helper.Env = append(os.Environ(), "FILEMAN_LEASE_DIR="+dir)
+	output, err := helper.StdoutPipe()
+	assert.Nil(t, err)
+	assert.Nil(t, helper.Start())
+
+	line, err := bufio.NewReader(output).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "locked\n", line)
+
+	locker := NewLeaseLocker(fs.FS{}, clock.RealClock{}, dir, time.Second)
+
+	time.Sleep(1500 * time.Millisecond)
+	_, err = locker.Lock(context.Background(), "/files/logs")
+	assert.ErrorIs(t, err, ErrLeaseHeld, "the lease is renewed by the other process")
+
+	assert.Nil(t, helper.Process.Kill())
+	_ = helper.Wait()
+
+	assert.Eventually(t, func() bool {
+		_, err := locker.Lock(context.Background(), "/files/logs")
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond, "the lease of the killed process expires")
+}
+
+func readLease(t *testing.T, path string) lease {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	current := lease{}
+	assert.Nil(t, json.Unmarshal(content, &current))
+
+	return current
+}
+
+func writeLease(t *testing.T, path string, current lease) {
+	t.Helper()
+
+	content, err := json.Marshal(current)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(path, content, 0o644))
+}
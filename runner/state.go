@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fileman/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// State records the last successful run of each job in a file, so that
+// the runs missed while fileman was down can be caught up on startup
+type State struct {
+	fs       fs.FileSystem
+	path     string
+	mutex    sync.Mutex
+	lastRuns map[string]time.Time
+}
+
+// stateFile is the content of the state file
+type stateFile struct {
+	LastRuns map[string]time.Time `json:"lastRuns"`
+}
+
+// LoadState reads the state file at a given path, a missing one holding
+// no runs yet
+func LoadState(fs fs.FileSystem, path string) (*State, error) {
+	state := &State{fs: fs, path: path, lastRuns: make(map[string]time.Time)}
+	content, err := fs.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	file := stateFile{}
+
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+
+	for key, lastRun := range file.LastRuns {
+		state.lastRuns[key] = lastRun
+	}
+
+	return state, nil
+}
+
+// LastRun returns the time of the last successful run of a job, which is
+// zero when it never ran
+func (s *State) LastRun(key string) time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lastRuns[key]
+}
+
+// Record saves the time of a successful run of a job. The state file is
+// replaced as a whole, so that a crash never leaves it half written.
+func (s *State) Record(key string, lastRun time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastRuns[key] = lastRun
+	content, err := json.MarshalIndent(stateFile{LastRuns: s.lastRuns}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	temporary := s.path + ".tmp"
+
+	if err := s.fs.WriteFile(temporary, content); err != nil {
+		return err
+	}
+
+	return s.fs.Rename(temporary, s.path)
+}
+
+// Missed tells whether a run of the schedule came due after the last run,
+// and before now. Jobs that never ran haven't missed any.
+func Missed(schedule cron.Schedule, lastRun time.Time, now time.Time) bool {
+	return !lastRun.IsZero() && schedule.Next(lastRun).Before(now)
+}
@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"fileman/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateRecordsLastRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	lastRun := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	state, err := LoadState(fs.FS{}, path)
+	assert.Nil(t, err)
+	assert.True(t, state.LastRun("/files/logs").IsZero())
+
+	assert.Nil(t, state.Record("/files/logs", lastRun))
+	assert.Nil(t, state.Record("/files/tmp", lastRun.Add(time.Hour)))
+	assert.Equal(t, lastRun, state.LastRun("/files/logs"))
+	assert.NoFileExists(t, path+".tmp")
+
+	reloaded, err := LoadState(fs.FS{}, path)
+	assert.Nil(t, err)
+	assert.True(t, lastRun.Equal(reloaded.LastRun("/files/logs")))
+	assert.True(t, lastRun.Add(time.Hour).Equal(reloaded.LastRun("/files/tmp")))
+}
+
+func TestLoadStateInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	assert.Nil(t, os.WriteFile(path, []byte("{"), 0o644))
+
+	_, err := LoadState(fs.FS{}, path)
+	assert.Error(t, err)
+}
+
+func TestMissed(t *testing.T) {
+	daily, err := cron.ParseStandard("CRON_TZ=UTC 0 3 * * *")
+	assert.Nil(t, err)
+
+	lastRun := time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	assert.False(t, Missed(daily, lastRun, lastRun.Add(23*time.Hour)))
+	assert.True(t, Missed(daily, lastRun, lastRun.Add(25*time.Hour)))
+	assert.False(t, Missed(daily, time.Time{}, lastRun.Add(25*time.Hour)))
+}
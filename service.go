@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fileman/clock"
+	"fileman/config"
+	"fileman/fs"
+	"fileman/handler"
+	"fileman/runner"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// service runs the jobs of the watched directories, and updates them as
+// the configuration is reloaded
+type service struct {
+	ctx         context.Context
+	logger      gocron.Logger
+	scheduler   gocron.Scheduler
+	clock       clock.Clock
+	fileHandler *handler.FileHandler
+	fileSystem  fs.FileSystem
+	summary     *handler.Summary
+	state       *runner.State
+	watchers    sync.WaitGroup
+	directories map[string]*directoryJobs
+}
+
+// directoryJobs are the jobs of a watched directory. Their tasks read the
+// settings of the directory when they run, so that they follow reloads.
+type directoryJobs struct {
+	settings     atomic.Pointer[settings]
+	deferred     atomic.Bool
+	cleaner      gocron.Job
+	spaceWatcher gocron.Job
+	stopWatching func()
+}
+
+// settings are the current settings of a watched directory along with the
+// guard its runs go through
+type settings struct {
+	directory config.WatchedDirectory
+	location  *time.Location
+	guard     *runner.Guard
+}
+
+func newService(ctx context.Context, logger gocron.Logger, scheduler gocron.Scheduler, clock clock.Clock, fileSystem fs.FileSystem, state *runner.State) *service {
+	return &service{
+		ctx:         ctx,
+		logger:      logger,
+		scheduler:   scheduler,
+		clock:       clock,
+		fileHandler: handler.New(clock),
+		fileSystem:  fileSystem,
+		summary:     &handler.Summary{},
+		state:       state,
+		directories: make(map[string]*directoryJobs),
+	}
+}
+
+// apply brings the jobs in line with the watched directories of the
+// configuration: the jobs of new directories are added, the ones of the
+// directories dropped removed and the ones of the directories whose
+// settings changed updated. On startup, the runs of the directories set to
+// run on start, or that missed one while fileman was down, are started.
+func (s *service) apply(configObject config.Config, startup bool) error {
+	errs := make([]error, 0)
+	kept := make(map[string]bool)
+
+	for _, directory := range configObject.Directories() {
+		kept[directory.Path] = true
+		jobs, exists := s.directories[directory.Path]
+
+		if !exists {
+			jobs = &directoryJobs{}
+			s.directories[directory.Path] = jobs
+		} else if current := jobs.settings.Load(); current != nil && reflect.DeepEqual(current.directory, directory) {
+			continue
+		}
+
+		if err := s.update(jobs, directory, startup); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", directory.Path, err))
+		}
+
+		switch {
+		case startup:
+		case exists:
+			s.logger.Info("Updated directory", directory.Path)
+		default:
+			s.logger.Info("Added directory", directory.Path)
+		}
+	}
+
+	for path, jobs := range s.directories {
+		if kept[path] {
+			continue
+		}
+
+		errs = append(errs, s.remove(path, jobs)...)
+		delete(s.directories, path)
+		s.logger.Info("Removed directory", path)
+	}
+
+	return errors.Join(errs...)
+}
+
+// update schedules the jobs of a watched directory for its new settings.
+// Its guard is kept unless its overlap policy or lock changed, so that
+// the run going, if any, doesn't overlap with the next ones.
+func (s *service) update(jobs *directoryJobs, directory config.WatchedDirectory, startup bool) error {
+	location, err := directory.Location()
+
+	if err != nil {
+		return err
+	}
+
+	previous := jobs.settings.Load()
+	current := &settings{directory: directory, location: location}
+
+	if previous != nil && previous.directory.Overlap == directory.Overlap && reflect.DeepEqual(previous.directory.Lock, directory.Lock) {
+		current.guard = previous.guard
+	} else {
+		if previous != nil {
+			previous.guard.Close()
+		}
+
+		current.guard = runner.NewGuard(directory.Overlap, s.locker(directory), directory.Path)
+	}
+
+	jobs.settings.Store(current)
+	errs := make([]error, 0)
+
+	if err := s.scheduleCleaner(jobs, previous, directory, startup); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := s.scheduleSpaceWatcher(jobs, previous, directory); err != nil {
+		errs = append(errs, err)
+	}
+
+	if jobs.stopWatching != nil {
+		jobs.stopWatching()
+		jobs.stopWatching = nil
+	}
+
+	if directory.Trigger == "inotify" {
+		s.watch(jobs, directory)
+	}
+
+	return errors.Join(errs...)
+}
+
+// remove unschedules the jobs of a watched directory, the one-time runs
+// started on startup or deferred to a maintenance window included. The run
+// going, if any, finishes on its own. The settings are missing when the
+// directory failed to be scheduled.
+func (s *service) remove(path string, jobs *directoryJobs) []error {
+	errs := make([]error, 0)
+	s.scheduler.RemoveByTags(oneTimeTag(path))
+
+	for _, job := range []gocron.Job{jobs.cleaner, jobs.spaceWatcher} {
+		if job == nil {
+			continue
+		}
+
+		if err := s.scheduler.RemoveJob(job.ID()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if jobs.stopWatching != nil {
+		jobs.stopWatching()
+	}
+
+	if current := jobs.settings.Load(); current != nil {
+		current.guard.Close()
+	}
+
+	return errs
+}
+
+// oneTimeTag tags the one-time jobs of a watched directory, to remove them
+// along with it
+func oneTimeTag(path string) string {
+	return "OneTime-" + path
+}
+
+// locker returns the locker of a watched directory, if it has a lock
+func (s *service) locker(directory config.WatchedDirectory) gocron.Locker {
+	if directory.Lock == nil {
+		return nil
+	}
+
+	ttl := directory.Lock.TTL
+	if ttl <= 0 {
+		ttl = 60
+	}
+
+	return runner.NewLeaseLocker(s.fileSystem, s.clock, directory.Lock.Dir, time.Duration(ttl)*time.Second)
+}
+
+// scheduleCleaner adds, updates or removes the cron job of a watched
+// directory. On startup, it also starts a run straight away when the
+// directory runs on start or missed one while fileman was down.
+func (s *service) scheduleCleaner(jobs *directoryJobs, previous *settings, directory config.WatchedDirectory, startup bool) error {
+	name := "PathCleaner-" + directory.Path
+	task := gocron.NewTask(func() { s.scheduled(jobs) })
+	var err error
+
+	switch {
+	case directory.Cron == "" && jobs.cleaner != nil:
+		err = s.scheduler.RemoveJob(jobs.cleaner.ID())
+		jobs.cleaner = nil
+	case directory.Cron == "":
+	case jobs.cleaner == nil:
+		jobs.cleaner, err = s.scheduler.NewJob(gocron.CronJob(directory.Crontab(), false), task, gocron.WithName(name))
+	case previous.directory.Crontab() != directory.Crontab():
+		jobs.cleaner, err = s.scheduler.Update(jobs.cleaner.ID(), gocron.CronJob(directory.Crontab(), false), task, gocron.WithName(name))
+	}
+
+	if err != nil || !startup || directory.Cron == "" {
+		return err
+	}
+
+	schedule, err := config.ParseCron(directory.Crontab())
+
+	if err != nil {
+		return err
+	}
+
+	lastRun := time.Time{}
+	if s.state != nil {
+		lastRun = s.state.LastRun(directory.Path)
+	}
+
+	if !directory.RunOnStart && !runner.Missed(schedule, lastRun, time.Now()) {
+		return nil
+	}
+
+	_, err = s.scheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartImmediately()),
+		task,
+		gocron.WithName("StartupCleaner-"+directory.Path),
+		gocron.WithTags(oneTimeTag(directory.Path)),
+		gocron.WithLimitedRuns(1),
+	)
+
+	if err == nil && !directory.RunOnStart {
+		s.logger.Info("Catching up missed run", directory.Path, "Last run", lastRun.String())
+	}
+
+	return err
+}
+
+// scheduleSpaceWatcher adds, updates or removes the job checking the free
+// space of a watched directory with a watermark
+func (s *service) scheduleSpaceWatcher(jobs *directoryJobs, previous *settings, directory config.WatchedDirectory) error {
+	name := "SpaceWatcher-" + directory.Path
+	interval := watermarkInterval(directory)
+	task := gocron.NewTask(func() {
+		current := jobs.settings.Load()
+
+		if !config.InWindow(current.directory.Windows, time.Now().In(current.location)) {
+			return
+		}
+
+		s.guarded(current, name, func() {
+			result := s.fileHandler.FreeSpace(s.fileSystem, current.directory)
+			if !result.IsEmpty() {
+				logResult(s.logger, s.summary, current.directory.Path, result)
+			}
+		})
+	})
+	var err error
+
+	switch {
+	case interval == 0 && jobs.spaceWatcher != nil:
+		err = s.scheduler.RemoveJob(jobs.spaceWatcher.ID())
+		jobs.spaceWatcher = nil
+	case interval == 0:
+	case jobs.spaceWatcher == nil:
+		jobs.spaceWatcher, err = s.scheduler.NewJob(gocron.DurationJob(interval), task, gocron.WithName(name))
+	case watermarkInterval(previous.directory) != interval:
+		jobs.spaceWatcher, err = s.scheduler.Update(jobs.spaceWatcher.ID(), gocron.DurationJob(interval), task, gocron.WithName(name))
+	}
+
+	return err
+}
+
+// watermarkInterval returns how often the free space of a watched
+// directory is checked, 0 when it has no watermark
+func watermarkInterval(directory config.WatchedDirectory) time.Duration {
+	if directory.Watermark == nil {
+		return 0
+	}
+
+	interval := directory.Watermark.Interval
+	if interval <= 0 {
+		interval = 60
+	}
+
+	return time.Duration(interval) * time.Second
+}
+
+// watch starts removing the files of a watched directory with the inotify
+// trigger as they expire
+func (s *service) watch(jobs *directoryJobs, directory config.WatchedDirectory) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+
+	s.watchers.Add(1)
+
+	go func() {
+		defer s.watchers.Done()
+		defer close(done)
+
+		guard := func(task func()) bool {
+			return s.guardedNow(jobs.settings.Load(), "FileWatcher-"+directory.Path, task)
+		}
+
+		s.fileHandler.Watch(ctx, s.fileSystem, directory, guard, func(result handler.Result) {
+			logResult(s.logger, s.summary, directory.Path, result)
+		})
+	}()
+
+	jobs.stopWatching = func() {
+		cancel()
+		<-done
+	}
+
+	s.logger.Info("Watching directory", directory.Path)
+}
+
+// scheduled runs a scheduled pass over a watched directory within its
+// maintenance windows, deferring it to the next one or skipping it
+// otherwise
+func (s *service) scheduled(jobs *directoryJobs) {
+	current := jobs.settings.Load()
+	directory := current.directory
+	now := time.Now().In(current.location)
+
+	if config.InWindow(directory.Windows, now) {
+		s.clean(current)
+		return
+	}
+
+	if directory.OutsideWindow != "defer" {
+		s.logger.Info("Skipped run outside maintenance window", directory.Path)
+		return
+	}
+
+	if !jobs.deferred.CompareAndSwap(false, true) {
+		s.logger.Info("Run already deferred to maintenance window", directory.Path)
+		return
+	}
+
+	next := config.NextWindow(directory.Windows, now)
+
+	_, err := s.scheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(next)),
+		gocron.NewTask(func() {
+			jobs.deferred.Store(false)
+			s.clean(jobs.settings.Load())
+		}),
+		gocron.WithName("DeferredCleaner-"+directory.Path),
+		gocron.WithTags(oneTimeTag(directory.Path)),
+		gocron.WithLimitedRuns(1),
+	)
+
+	if err != nil {
+		jobs.deferred.Store(false)
+		s.logger.Error("Error deferring run to maintenance window", directory.Path, "Error", err.Error())
+		return
+	}
+
+	s.logger.Info("Deferred run to maintenance window", directory.Path, "At", next.String())
+}
+
+// clean runs a pass over a watched directory, recording it in the state
+// when it succeeds
+func (s *service) clean(current *settings) {
+	directory := current.directory
+
+	s.guarded(current, "PathCleaner-"+directory.Path, func() {
+		result := s.fileHandler.Clean(s.fileSystem, directory)
+		logResult(s.logger, s.summary, directory.Path, result)
+
+		if s.state == nil || result.DryRun || len(result.Errors) > 0 {
+			return
+		}
+
+		if err := s.state.Record(directory.Path, time.Now()); err != nil {
+			s.logger.Error("Error recording run", directory.Path, "Error", err.Error())
+		}
+	})
+}
+
+// guarded runs a task of a watched directory through its guard, logging
+// why it didn't run
+func (s *service) guarded(current *settings, name string, task func()) {
+	outcome, err := current.guard.Run(task)
+	s.logOutcome(current, name, outcome, err)
+}
+
+// guardedNow runs a task of a watched directory through its guard unless
+// another run is going, whatever the overlap policy, telling whether it ran
+func (s *service) guardedNow(current *settings, name string, task func()) bool {
+	outcome, err := current.guard.RunNow(task)
+	s.logOutcome(current, name, outcome, err)
+
+	return outcome == runner.Ran
+}
+
+// logOutcome logs why a task of a watched directory didn't run, or why
+// its lock couldn't be released
+func (s *service) logOutcome(current *settings, name string, outcome runner.Outcome, err error) {
+	path := current.directory.Path
+
+	switch {
+	case outcome == runner.Skipped:
+		s.logger.Info("Skipped run as the previous one is still going", path, "Job", name)
+	case outcome == runner.Queued:
+		s.logger.Info("Queued run until the previous one ends", path, "Job", name)
+	case outcome == runner.Closed:
+		s.logger.Info("Skipped run as fileman is shutting down", path, "Job", name)
+	case outcome == runner.Locked && errors.Is(err, runner.ErrLeaseHeld):
+		s.logger.Info("Skipped run as another replica holds the lock", path, "Job", name, "Lock", err.Error())
+	case outcome == runner.Locked:
+		s.logger.Error("Error taking lock", path, "Job", name, "Error", err.Error())
+	case err != nil:
+		s.logger.Error("Error releasing lock", path, "Job", name, "Error", err.Error())
+	}
+}
+
+// shutdown stops the scheduler and the watchers, waiting up to the grace
+// period for the passes going to finish, and logs a summary of the passes
+// since startup. It returns the exit status: 0 when every pass finished,
+// 1 when the grace period ran out first.
+func (s *service) shutdown(gracePeriod time.Duration) int {
+	s.logger.Info("Shutting down, waiting for the passes going to finish", "Grace period", gracePeriod.String())
+
+	deadline := time.Now().Add(gracePeriod)
+	status := 0
+
+	for _, jobs := range s.directories {
+		if current := jobs.settings.Load(); current != nil {
+			current.guard.Close()
+		}
+	}
+
+	if err := s.scheduler.Shutdown(); err != nil {
+		s.logger.Error("Error shutting down scheduler", "Error", err.Error())
+		status = 1
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.watchers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Until(deadline)):
+		s.logger.Error("Grace period ran out before the watchers stopped")
+		status = 1
+	}
+
+	totals := s.summary.Totals()
+	s.logger.Info("Shutdown complete", "Removed files", totals.Removed, "Compressed files", totals.Compressed, "Errors", totals.Errors)
+
+	return status
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fileman/clock"
+	"fileman/config"
+	"fileman/fs"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// startService returns a service over a scheduler that isn't started, so
+// that its jobs are only listed, shut down once the test ends
+func startService(t *testing.T, fakeClock *clock.FakeClock) (*service, gocron.Scheduler) {
+	t.Helper()
+
+	scheduler, err := gocron.NewScheduler()
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service := newService(ctx, gocron.NewLogger(gocron.LogLevelError), scheduler, fakeClock, fs.FS{}, nil)
+
+	t.Cleanup(func() {
+		cancel()
+		service.shutdown(time.Second)
+	})
+
+	return service, scheduler
+}
+
+// jobNames returns the names of the jobs of the scheduler, sorted
+func jobNames(scheduler gocron.Scheduler) []string {
+	names := make([]string, 0)
+	for _, job := range scheduler.Jobs() {
+		names = append(names, job.Name())
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func TestServiceAppliesChanges(t *testing.T) {
+	service, scheduler := startService(t, clock.NewFakeClock(time.Now()))
+
+	assert.Nil(t, service.apply(config.Config{
+		Cron:               "0 * * * *",
+		WatchedDirectories: []config.WatchedDirectory{{Path: "/srv/a"}, {Path: "/srv/b"}},
+	}, false))
+	assert.Equal(t, []string{"PathCleaner-/srv/a", "PathCleaner-/srv/b"}, jobNames(scheduler))
+
+	cleaner := service.directories["/srv/a"].cleaner.ID()
+	guard := service.directories["/srv/a"].settings.Load().guard
+
+	assert.Nil(t, service.apply(config.Config{
+		Cron:               "0 * * * *",
+		WatchedDirectories: []config.WatchedDirectory{{Path: "/srv/a", Cron: "30 * * * *"}, {Path: "/srv/c"}},
+	}, false))
+	assert.Equal(t, []string{"PathCleaner-/srv/a", "PathCleaner-/srv/c"}, jobNames(scheduler))
+
+	current := service.directories["/srv/a"].settings.Load()
+	assert.Equal(t, cleaner, service.directories["/srv/a"].cleaner.ID())
+	assert.Equal(t, "30 * * * *", current.directory.Cron)
+	assert.Same(t, guard, current.guard)
+	assert.NotContains(t, service.directories, "/srv/b")
+}
+
+func TestServiceRemovesOneTimeJobs(t *testing.T) {
+	service, scheduler := startService(t, clock.NewFakeClock(time.Now()))
+
+	assert.Nil(t, service.apply(config.Config{
+		Cron:               "0 * * * *",
+		RunOnStart:         true,
+		WatchedDirectories: []config.WatchedDirectory{{Path: "/srv/a"}, {Path: "/srv/b"}},
+	}, true))
+	assert.Equal(t, []string{"PathCleaner-/srv/a", "PathCleaner-/srv/b", "StartupCleaner-/srv/a", "StartupCleaner-/srv/b"}, jobNames(scheduler))
+
+	assert.Nil(t, service.apply(config.Config{
+		Cron:               "0 * * * *",
+		RunOnStart:         true,
+		WatchedDirectories: []config.WatchedDirectory{{Path: "/srv/b"}},
+	}, false))
+	assert.Equal(t, []string{"PathCleaner-/srv/b", "StartupCleaner-/srv/b"}, jobNames(scheduler))
+}
+
+func TestServiceSurvivesAnInvalidReload(t *testing.T) {
+	service, scheduler := startService(t, clock.NewFakeClock(time.Now()))
+	invalid := config.Config{
+		Cron:               "0 * * * *",
+		WatchedDirectories: []config.WatchedDirectory{{Path: "/srv/a", Timezone: "Mars/Olympus"}},
+	}
+
+	assert.ErrorContains(t, service.apply(invalid, false), "/srv/a: ")
+	assert.Empty(t, jobNames(scheduler))
+
+	assert.Nil(t, service.apply(config.Config{Cron: "0 * * * *"}, false))
+	assert.Empty(t, service.directories)
+
+	assert.Error(t, service.apply(invalid, false))
+	assert.Error(t, service.apply(invalid, false))
+
+	invalid.WatchedDirectories[0].Timezone = "UTC"
+	assert.Nil(t, service.apply(invalid, false))
+	assert.Equal(t, []string{"PathCleaner-/srv/a"}, jobNames(scheduler))
+}
+
+func TestServiceWatchesDirectoriesUntilRemoved(t *testing.T) {
+	watched := t.TempDir()
+	fakeClock := clock.NewFakeClock(time.Now())
+	service, _ := startService(t, fakeClock)
+	old := filepath.Join(watched, "old.log")
+	created := filepath.Join(watched, "created.log")
+
+	modTime := fakeClock.Now().Add(-48 * time.Hour)
+	assert.Nil(t, os.WriteFile(old, []byte("old"), 0o644))
+	assert.Nil(t, os.Chtimes(old, modTime, modTime))
+
+	assert.Nil(t, service.apply(config.Config{
+		WatchedDirectories: []config.WatchedDirectory{{Path: watched, Trigger: "inotify", Age: config.Days(1)}},
+	}, false))
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(old)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, service.apply(config.Config{}, false))
+	service.watchers.Wait()
+
+	assert.Nil(t, os.WriteFile(created, []byte("created"), 0o644))
+	fakeClock.Advance(48 * time.Hour)
+	assert.FileExists(t, created)
+}